@@ -0,0 +1,120 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Approximate counting in fixed memory, using the Space-Saving algorithm (Metwally, Agrawal & El Abbadi, 2005).
+// At most capacity keys are kept. When a new key is added to a full counter, the key with the lowest count is
+// replaced, and the new key inherits its count as the error bound. For each key, the true count is between
+// count - error and count, and every key with a true count above total/capacity is guaranteed to be kept.
+
+const (
+	counterExact       = "exact"
+	counterSpaceSaving = "spacesaving"
+)
+
+type ssEntry struct {
+	key   string
+	count int
+	err   int // max overestimation of count
+	index int // position in the heap
+}
+
+// spaceSaving is a min heap of the counted keys, with an index by key
+type spaceSaving struct {
+	capacity int
+	total    int
+	entries  []*ssEntry
+	index    map[string]*ssEntry
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{capacity: capacity, index: make(map[string]*ssEntry, capacity)}
+}
+
+func (ss *spaceSaving) Len() int           { return len(ss.entries) }
+func (ss *spaceSaving) Less(i, j int) bool { return ss.entries[i].count < ss.entries[j].count }
+func (ss *spaceSaving) Swap(i, j int) {
+	ss.entries[i], ss.entries[j] = ss.entries[j], ss.entries[i]
+	ss.entries[i].index = i
+	ss.entries[j].index = j
+}
+func (ss *spaceSaving) Push(x interface{}) {
+	e := x.(*ssEntry)
+	e.index = len(ss.entries)
+	ss.entries = append(ss.entries, e)
+}
+func (ss *spaceSaving) Pop() interface{} {
+	e := ss.entries[len(ss.entries)-1]
+	ss.entries = ss.entries[:len(ss.entries)-1]
+	return e
+}
+
+// add adds f occurrences of the key
+func (ss *spaceSaving) add(key string, f int) {
+	ss.total += f
+	if e, ok := ss.index[key]; ok {
+		e.count += f
+		heap.Fix(ss, e.index)
+		return
+	}
+	if len(ss.entries) < ss.capacity {
+		e := &ssEntry{key: key, count: f}
+		heap.Push(ss, e)
+		ss.index[key] = e
+		return
+	}
+	// replace the key with the lowest count
+	e := ss.entries[0]
+	delete(ss.index, e.key)
+	e.key = key
+	e.err = e.count
+	e.count += f
+	ss.index[key] = e
+	heap.Fix(ss, 0)
+}
+
+// addAll adds the counts, in key order (so that the result doesn't depend on the map iteration order)
+func (ss *spaceSaving) addAll(freqs map[string]int) {
+	var keys = make([]string, 0, len(freqs))
+	for k := range freqs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ss.add(k, freqs[k])
+	}
+}
+
+// errorBound returns the max error of any count (total/capacity)
+func (ss *spaceSaving) errorBound() int {
+	return ss.total / ss.capacity
+}
+
+// sorted returns the entries sorted by count, as sortByWordCount
+func (ss *spaceSaving) sorted() []ssEntry {
+	var result = make([]ssEntry, len(ss.entries))
+	for i, e := range ss.entries {
+		result[i] = *e
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].count > result[j].count || (result[i].count == result[j].count && result[i].key < result[j].key)
+	})
+	return result
+}
+
+// writeFreqList writes the approximate frequency list (limited by min freq), with the error bound of each count
+// as a third column. It returns the number of keys kept.
+func (ss *spaceSaving) writeFreqList(lw listWriter, minFreq int) (int, error) {
+	for _, e := range ss.sorted() {
+		if e.count < minFreq {
+			continue
+		}
+		if err := lw.writeRow(e.count, e.key, e.err); err != nil {
+			return 0, err
+		}
+	}
+	return len(ss.entries), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpaceSaving(t *testing.T) {
+	var ss = newSpaceSaving(3)
+	for _, w := range []string{"a", "b", "a", "c", "a", "d", "b", "a"} {
+		ss.add(w, 1)
+	}
+	var expect = []ssEntry{
+		{key: "a", count: 4, err: 0},
+		{key: "b", count: 2, err: 1},
+		{key: "d", count: 2, err: 1},
+	}
+	var result = ss.sorted()
+	for i := range result {
+		result[i].index = 0
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(fsExp, expect, result)
+	}
+	if ss.errorBound() != 2 {
+		t.Errorf(fsExp, 2, ss.errorBound())
+	}
+}
+
+func TestLoadSpaceSaving(t *testing.T) {
+	var opts = loadOptions{pageLimit: -1, logAt: 100}
+	exact := loadXML(testXML, opts)
+
+	// with a capacity larger than the vocabulary, the counts are exact
+	opts.counter = counterSpaceSaving
+	opts.capacity = 1000
+	result := loadXML(testXML, opts)
+	for _, e := range result.approxWords.sorted() {
+		if e.count != exact.wordFreqs[e.key] || e.err != 0 {
+			t.Errorf("unexpected count for %s: %d (error %d), expected %d", e.key, e.count, e.err, exact.wordFreqs[e.key])
+		}
+	}
+
+	// with a small capacity, the true count is within the error bound, and the result is the same for parallel processing
+	opts.capacity = 10
+	opts.workers = 1
+	seq := loadXML(testDump, opts)
+	opts.workers = 3
+	par := loadXML(testDump, opts)
+	if !reflect.DeepEqual(seq.approxWords.sorted(), par.approxWords.sorted()) {
+		t.Errorf(fsExp, seq.approxWords.sorted(), par.approxWords.sorted())
+	}
+	for _, e := range seq.approxWords.sorted() {
+		f := exact.wordFreqs[e.key]
+		if f > e.count || f < e.count-e.err {
+			t.Errorf("count for %s not within error bound: %d (error %d), expected %d", e.key, e.count, e.err, f)
+		}
+	}
+	for w, f := range exact.wordFreqs {
+		if _, ok := seq.approxWords.index[w]; !ok && f > seq.approxWords.errorBound() {
+			t.Errorf("expected %s (count %d) to be kept", w, f)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Case preserving counting: with -case, the surface forms of the words are counted (Bush and bush are different
+// words), and the case variants of each word can be listed: the folded (lower case) word with its total count, its
+// most frequent surface form, and the share of its sentence internal occurrences that are capitalised, to help
+// separate proper nouns from common words, and to find acronyms. The first word of a sentence is capitalised
+// regardless of the word, and is not counted as sentence internal. The case variants need the sentences of the
+// wikitext parser (with the line based parser, each line is a sentence).
+
+// caseFreqs are the no. of sentence internal occurrences of each folded word, and how many of them are capitalised
+type caseFreqs struct {
+	Internal    map[string]int
+	Capitalised map[string]int
+}
+
+func newCaseFreqs() *caseFreqs {
+	return &caseFreqs{Internal: make(map[string]int), Capitalised: make(map[string]int)}
+}
+
+// isCapitalised is true if the word starts with an upper (or title) case letter
+func isCapitalised(w string) bool {
+	r, _ := utf8.DecodeRuneInString(w)
+	return unicode.IsUpper(r) || unicode.IsTitle(r)
+}
+
+// addSentences counts the sentence internal occurrences of the words (all words but the first of each sentence)
+func (cf *caseFreqs) addSentences(sentences [][]string) {
+	for _, words := range sentences {
+		for i := 1; i < len(words); i++ {
+			var folded = strings.ToLower(words[i])
+			cf.Internal[folded]++
+			if isCapitalised(words[i]) {
+				cf.Capitalised[folded]++
+			}
+		}
+	}
+}
+
+func (cf *caseFreqs) addAll(other *caseFreqs) {
+	for w, f := range other.Internal {
+		cf.Internal[w] += f
+	}
+	for w, f := range other.Capitalised {
+		cf.Capitalised[w] += f
+	}
+}
+
+type caseVariant struct {
+	word        string // folded
+	count       int
+	form        string // the most frequent surface form
+	formCount   int
+	internal    int     // no. of sentence internal occurrences
+	capitalised float64 // share of the sentence internal occurrences that are capitalised (0 if none)
+}
+
+// caseVariants groups the surface form counts by folded word, and returns the words with a total count of at least
+// minFreq, sorted by count, and then by word. If two surface forms are equally frequent, the first in byte order
+// (i.e. upper case before lower case) is used.
+func caseVariants(wordFreqs map[string]int, cf *caseFreqs, minFreq int) []caseVariant {
+	var variants = make(map[string]*caseVariant)
+	for w, f := range wordFreqs {
+		var folded = strings.ToLower(w)
+		v, ok := variants[folded]
+		if !ok {
+			v = &caseVariant{word: folded}
+			variants[folded] = v
+		}
+		v.count += f
+		if f > v.formCount || (f == v.formCount && w < v.form) {
+			v.form = w
+			v.formCount = f
+		}
+	}
+	var result []caseVariant
+	for _, v := range variants {
+		if v.count < minFreq {
+			continue
+		}
+		v.internal = cf.Internal[v.word]
+		if v.internal > 0 {
+			v.capitalised = float64(cf.Capitalised[v.word]) / float64(v.internal)
+		}
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].count > result[j].count || (result[i].count == result[j].count && result[i].word < result[j].word)
+	})
+	return result
+}
+
+// writeCaseVariants writes the case variants (limited by min freq) to the case file, or to the casevariants table
+// for sqlite. It returns the number of words written.
+func writeCaseVariants(result loadResult, out outputOptions) (int, error) {
+	var path = out.caseFile
+	if out.format == formatSQLite {
+		path = out.file
+	}
+	lw, err := newListWriter(out.format, path, "casevariants", []column{{"word", "TEXT"}, {"count", "INTEGER"}, {"form", "TEXT"}, {"internal", "INTEGER"}, {"capitalised", "REAL"}})
+	if err != nil {
+		return 0, err
+	}
+	var variants = caseVariants(result.wordFreqs, result.caseFreqs, out.minFreq)
+	for _, v := range variants {
+		if err := lw.writeRow(v.word, v.count, v.form, v.internal, v.capitalised); err != nil {
+			lw.close()
+			return 0, err
+		}
+	}
+	return len(variants), lw.close()
+}
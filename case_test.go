@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCaseVariants(t *testing.T) {
+	var sentences = [][]string{
+		{"Bush", "talade", "om", "EU"},
+		{"En", "bush", "i", "Texas"},
+		{"President", "Bush", "och", "EU"},
+		{"Bush", "och", "bush"},
+	}
+	var cf = newCaseFreqs()
+	cf.addSentences(sentences)
+	var expect = []caseVariant{
+		{word: "bush", count: 5, form: "Bush", formCount: 3, internal: 3, capitalised: 1.0 / 3},
+		{word: "eu", count: 2, form: "EU", formCount: 2, internal: 2, capitalised: 1},
+		{word: "och", count: 2, form: "och", formCount: 2, internal: 2, capitalised: 0},
+	}
+	var result = caseVariants(countWords(sentences), cf, 2)
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(fsExp, expect, result)
+	}
+}
+
+func TestLoadCase(t *testing.T) {
+	var opts = loadOptions{pageLimit: -1, logAt: 100}
+	var folded = loadXML(testXML, opts)
+
+	opts.tkOpts.keepCase = true
+	opts.caseVariants = true
+	var result = loadXML(testXML, opts)
+	if result.nWords != folded.nWords {
+		t.Errorf(fsExp, folded.nWords, result.nWords)
+	}
+	var hasUpper = false
+	for w := range result.wordFreqs {
+		if w != strings.ToLower(w) {
+			hasUpper = true
+		}
+	}
+	if !hasUpper {
+		t.Errorf("expected upper case words, found none")
+	}
+	for _, v := range caseVariants(result.wordFreqs, result.caseFreqs, 0) {
+		if v.count != folded.wordFreqs[v.word] {
+			t.Errorf("unexpected count for %s: %d, expected %d", v.word, v.count, folded.wordFreqs[v.word])
+		}
+		if v.capitalised < 0 || v.capitalised > 1 || v.internal > v.count {
+			t.Errorf("unexpected case variant: %#v", v)
+		}
+	}
+}
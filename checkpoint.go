@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoints are written periodically to disk during long runs, so that an interrupted run can be resumed (see -resume).
+// A checkpoint holds the counts so far, and the position in the input: a raw (compressed) byte offset when known,
+// otherwise only the number of pages read, in which case the pages before the checkpoint are skipped when resuming.
+
+type checkpoint struct {
+	Path          string // input path
+	Offset        int64  // raw input offset to continue from, -1 if unknown
+	PagesRead     int    // no. of pages read, including pages not counted
+	LastPageID    int
+	NPages        int
+	NRedirects    int
+	NRevisions    int
+	NLines        int
+	NLinesSkipped int
+	NWords        int
+	NNgrams       int
+	NSPages       map[int]int
+	SiteInfo      SiteInfo
+	WordFreqs     map[string]int
+	NgramFreqs    map[string]int
+	DocFreqs      map[string]int
+	NgramDocFreqs map[string]int
+	PartFreqs     map[string]partFreqs
+	PartSizes     []int
+	BucketFreqs   bucketFreqs
+	CaseFreqs     *caseFreqs
+	SentLengths   map[int]int
+	SpillRuns     map[string][]string // run files written to disk (see spill.go)
+}
+
+type checkpointer struct {
+	path          string // checkpoint file
+	input         string // input path
+	every         int    // no. of pages between checkpoints
+	lastPagesRead int
+}
+
+func newCheckpointer(path string, input string, every int) *checkpointer {
+	return &checkpointer{path: path, input: input, every: every}
+}
+
+func (c *checkpointer) due(pagesRead int) bool {
+	return c != nil && pagesRead-c.lastPagesRead >= c.every
+}
+
+// save writes the checkpoint to a temporary file, and then renames it, so that a crash while saving will not
+// destroy the previous checkpoint
+func (c *checkpointer) save(result loadResult, offset int64) error {
+	c.lastPagesRead = result.pagesRead
+	var cp = checkpoint{
+		Path:          c.input,
+		Offset:        offset,
+		PagesRead:     result.pagesRead,
+		LastPageID:    result.lastPageID,
+		NPages:        result.nPages,
+		NRedirects:    result.nRedirects,
+		NRevisions:    result.nRevisions,
+		NLines:        result.nLines,
+		NLinesSkipped: result.nLinesSkipped,
+		NWords:        result.nWords,
+		NNgrams:       result.nNgrams,
+		NSPages:       result.nsPages,
+		SiteInfo:      result.siteInfo,
+		WordFreqs:     result.wordFreqs,
+		NgramFreqs:    result.ngramFreqs,
+		DocFreqs:      result.docFreqs,
+		NgramDocFreqs: result.ngramDocFreqs,
+		PartFreqs:     result.partFreqs,
+		PartSizes:     result.partSizes,
+		BucketFreqs:   result.bucketFreqs,
+		CaseFreqs:     result.caseFreqs,
+		SentLengths:   result.sentLengths,
+	}
+	if result.spill != nil {
+		cp.SpillRuns = result.spill.runs
+	}
+	tmp, err := os.Create(c.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(cp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// saveIfDue saves a checkpoint if enough pages have been read since the last one
+func (c *checkpointer) saveIfDue(result loadResult, offset int64) {
+	if !c.due(result.pagesRead) {
+		return
+	}
+	if err := c.save(result, offset); err != nil {
+		log.Fatal("Couldn't save checkpoint: ", err)
+	}
+}
+
+func readCheckpoint(path string) (checkpoint, error) {
+	var cp checkpoint
+	file, err := os.Open(path)
+	if err != nil {
+		return cp, err
+	}
+	defer file.Close()
+	err = gob.NewDecoder(file).Decode(&cp)
+	return cp, err
+}
+
+// resume returns the load result stored in the checkpoint
+func (cp checkpoint) resume(path string, opts loadOptions) (loadResult, error) {
+	abs1, _ := filepath.Abs(cp.Path)
+	abs2, _ := filepath.Abs(path)
+	if cp.Path != path && abs1 != abs2 {
+		return loadResult{}, fmt.Errorf("checkpoint was created for another input file: %s", cp.Path)
+	}
+	var result = newLoadResult(opts)
+	result.pagesRead = cp.PagesRead
+	result.lastPageID = cp.LastPageID
+	result.nPages = cp.NPages
+	result.nRedirects = cp.NRedirects
+	result.nRevisions = cp.NRevisions
+	result.nLines = cp.NLines
+	result.nLinesSkipped = cp.NLinesSkipped
+	result.nWords = cp.NWords
+	result.nNgrams = cp.NNgrams
+	if cp.NSPages != nil {
+		result.nsPages = cp.NSPages
+	}
+	if cp.WordFreqs != nil {
+		result.wordFreqs = cp.WordFreqs
+	}
+	if cp.NgramFreqs != nil {
+		result.ngramFreqs = cp.NgramFreqs
+	}
+	if len(cp.PartSizes) != len(result.partSizes) {
+		return loadResult{}, fmt.Errorf("checkpoint was created with another no. of corpus parts for dispersion: %d", len(cp.PartSizes))
+	}
+	if cp.DocFreqs != nil {
+		result.docFreqs = cp.DocFreqs
+		result.ngramDocFreqs = cp.NgramDocFreqs
+	}
+	if (cp.BucketFreqs != nil) != (result.bucketFreqs != nil) {
+		return loadResult{}, fmt.Errorf("checkpoint was created with other time buckets (-timebuckets)")
+	}
+	if cp.BucketFreqs != nil {
+		result.bucketFreqs = cp.BucketFreqs
+	}
+	if (cp.CaseFreqs != nil) != (result.caseFreqs != nil) {
+		return loadResult{}, fmt.Errorf("checkpoint was created with other case variant settings (-case, -casefile)")
+	}
+	if cp.CaseFreqs != nil {
+		result.caseFreqs = cp.CaseFreqs
+	}
+	if cp.SentLengths != nil {
+		result.sentLengths = cp.SentLengths
+	}
+	if cp.PartFreqs != nil {
+		result.partFreqs = cp.PartFreqs
+		result.partSizes = cp.PartSizes
+	}
+	if len(cp.SiteInfo.Namespaces) > 0 {
+		result.siteInfo = cp.SiteInfo
+		result.tk = newTokenizer(cp.SiteInfo, opts.tkOpts)
+	}
+	if len(cp.SpillRuns) > 0 {
+		if result.spill == nil {
+			return loadResult{}, fmt.Errorf("checkpoint was created with a memory budget (-maxmem)")
+		}
+		for name, runs := range cp.SpillRuns {
+			result.spill.runs[name] = append([]string{}, runs...)
+		}
+	}
+	if result.spill != nil {
+		// the memory budget applies to the restored counts too
+		result.spill.addSize(result.wordFreqs, result.ngramFreqs)
+	}
+	if cp.Offset < 0 {
+		result.skipPages = cp.PagesRead
+	}
+	return result, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// sameCounts compares the counts of two load results, ignoring the input position (pages read, last page id)
+func sameCounts(a loadResult, b loadResult) bool {
+	return a.nPages == b.nPages && a.nRedirects == b.nRedirects && a.nLines == b.nLines && a.nLinesSkipped == b.nLinesSkipped && a.nWords == b.nWords && reflect.DeepEqual(a.wordFreqs, b.wordFreqs) && reflect.DeepEqual(a.ngramFreqs, b.ngramFreqs) && reflect.DeepEqual(a.docFreqs, b.docFreqs) && reflect.DeepEqual(a.partFreqs, b.partFreqs) && reflect.DeepEqual(a.partSizes, b.partSizes) && reflect.DeepEqual(a.nsPages, b.nsPages) && reflect.DeepEqual(a.siteInfo, b.siteInfo)
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a bz2 dump that is not named multistream is read sequentially, and resumed by skipping pages
+	singleStream := filepath.Join(dir, "svwiki-test-pages-articles.xml.bz2")
+	data, err := ioutil.ReadFile(testDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(singleStream, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path       string
+		expectSkip bool
+	}{
+		{testXML, false},
+		{testDump, false},
+		{singleStream, true},
+	}
+	for _, test := range tests {
+		var opts = loadOptions{pageLimit: -1, logAt: 100}
+		expect := loadXML(test.path, opts)
+
+		cpFile := filepath.Join(dir, filepath.Base(test.path)+".checkpoint")
+		// interrupted run
+		opts.pageLimit = 5
+		opts.checkpoint = newCheckpointer(cpFile, test.path, 2)
+		loadXML(test.path, opts)
+
+		cp, err := readCheckpoint(cpFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cp.PagesRead == 0 || cp.PagesRead >= 8 {
+			t.Errorf("unexpected no. of pages read in checkpoint for %s: %d", test.path, cp.PagesRead)
+		}
+		if (cp.Offset < 0) != test.expectSkip {
+			t.Errorf("unexpected offset in checkpoint for %s: %d", test.path, cp.Offset)
+		}
+
+		// resumed run
+		opts.pageLimit = -1
+		opts.checkpoint = newCheckpointer(cpFile, test.path, 2)
+		opts.resume = true
+		result := loadXML(test.path, opts)
+		if !sameCounts(expect, result) {
+			t.Errorf("resumed run for %s: "+fsExp, test.path, expect, result)
+		}
+		if result.pagesRead != 8 || result.lastPageID != 8 {
+			t.Errorf("resumed run for %s: unexpected pages read/last page id: %d/%d", test.path, result.pagesRead, result.lastPageID)
+		}
+	}
+}
+
+func TestCheckpointOtherInput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cpFile := filepath.Join(dir, "checkpoint")
+	c := newCheckpointer(cpFile, testXML, 1)
+	if err := c.save(newLoadResult(loadOptions{}), 0); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := readCheckpoint(cpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cp.resume(testDump, loadOptions{}); err == nil {
+		t.Errorf("expected error when resuming with another input file")
+	}
+}
+
+func TestCheckpointResumeSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the resumed run spills at the same point as an uninterrupted run, since the counts restored from the
+	// checkpoint are included in the memory use
+	for _, budget := range []int{1500, 3000} {
+		var opts = loadOptions{pageLimit: -1, logAt: 100, memBudget: budget, spillDir: dir}
+		expect := loadXML(testXML, opts)
+
+		cpFile := filepath.Join(dir, "spill.checkpoint")
+		opts.pageLimit = 5
+		opts.checkpoint = newCheckpointer(cpFile, testXML, 2)
+		loadXML(testXML, opts)
+
+		opts.pageLimit = -1
+		opts.checkpoint = newCheckpointer(cpFile, testXML, 2)
+		opts.resume = true
+		result := loadXML(testXML, opts)
+		if n1, n2 := len(expect.spill.runs[spillWords]), len(result.spill.runs[spillWords]); n1 != n2 {
+			t.Errorf("budget %d: expected %d runs, got %d", budget, n1, n2)
+		}
+		if expect.spill.size != result.spill.size {
+			t.Errorf("budget %d: "+fsExp, budget, expect.spill.size, result.spill.size)
+		}
+	}
+}
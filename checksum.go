@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Verification of the input file against the checksums published with the Wikimedia dumps, e.g.
+// https://dumps.wikimedia.org/svwiki/latest/svwiki-latest-sha1sums.txt (or -md5sums.txt).
+// The digest is computed on the raw (compressed) input while it is being read.
+
+type checksum struct {
+	algorithm string // md5 or sha1
+	expect    string // hex digest, from the checksums file (empty if the digest is computed for the manifest only)
+	hash      hash.Hash
+	digest    string // hex digest of the input, after verify
+}
+
+// inputName returns the file name of a local file or url
+func inputName(input string) string {
+	if strings.HasPrefix(input, "http") {
+		if u, err := url.Parse(input); err == nil {
+			return path.Base(u.Path)
+		}
+	}
+	return filepath.Base(input)
+}
+
+// readChecksum reads the checksum of the input file from a checksums file (file or url), with lines
+// consisting of a hex digest and a file name
+func readChecksum(sumsPath string, input string) (*checksum, error) {
+	r, err := openInput(sumsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var name = inputName(input)
+	var scanner = bufio.NewScanner(r)
+	for scanner.Scan() {
+		fs := strings.Fields(scanner.Text())
+		// a file name prefixed by * means binary mode (md5sum/sha1sum output)
+		if len(fs) != 2 || strings.TrimPrefix(fs[1], "*") != name {
+			continue
+		}
+		var c = checksum{expect: strings.ToLower(fs[0])}
+		switch len(c.expect) {
+		case 2 * md5.Size:
+			c.algorithm, c.hash = "md5", md5.New()
+		case 2 * sha1.Size:
+			c.algorithm, c.hash = "sha1", sha1.New()
+		default:
+			return nil, fmt.Errorf("unknown checksum type for %s in %s: %s", name, sumsPath, fs[0])
+		}
+		return &c, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no checksum for %s in %s", name, sumsPath)
+}
+
+// wrap returns a reader that adds the data read to the digest
+func (c *checksum) wrap(r io.ReadCloser) io.ReadCloser {
+	if c == nil {
+		return r
+	}
+	c.hash.Reset()
+	return readCloser{io.TeeReader(r, c.hash), r}
+}
+
+// verify reads the rest of the input (if the whole file was not needed), and compares the digest to the expected checksum
+func (c *checksum) verify(r io.Reader, input string) error {
+	if c == nil {
+		return nil
+	}
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return err
+	}
+	c.digest = hex.EncodeToString(c.hash.Sum(nil))
+	if c.expect != "" && c.digest != c.expect {
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s (truncated or corrupt file?)", c.algorithm, input, c.expect, c.digest)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	dump, err := ioutil.ReadFile(testDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xml, err := ioutil.ReadFile(testXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var sha1sums = filepath.Join(dir, "svwiki-test-sha1sums.txt")
+	var md5sums = filepath.Join(dir, "svwiki-test-md5sums.txt")
+	var sums = fmt.Sprintf("%x  %s\n%x  %s\n", sha1.Sum(dump), filepath.Base(testDump), sha1.Sum(xml), filepath.Base(testXML))
+	if err := ioutil.WriteFile(sha1sums, []byte(sums), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sums = fmt.Sprintf("%x *%s\n", md5.Sum(dump), filepath.Base(testDump))
+	if err := ioutil.WriteFile(md5sums, []byte(sums), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		sums      string
+		input     string
+		algorithm string
+	}{
+		{sha1sums, testDump, "sha1"},
+		{sha1sums, testXML, "sha1"},
+		{sha1sums, "https://dumps.wikimedia.org/svwiki/latest/" + filepath.Base(testDump), "sha1"},
+		{md5sums, testDump, "md5"},
+		{md5sums, testXML, ""},
+	}
+	for _, test := range tests {
+		c, err := readChecksum(test.sums, test.input)
+		if test.algorithm == "" {
+			if err == nil {
+				t.Errorf("expected error for %s in %s", test.input, test.sums)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %s in %s: %v", test.input, test.sums, err)
+			continue
+		}
+		if c.algorithm != test.algorithm {
+			t.Errorf(fsExp, test.algorithm, c.algorithm)
+		}
+	}
+
+	// a correct checksum, for sequential and parallel reading, also with a page limit
+	for _, opts := range []loadOptions{
+		{pageLimit: -1, logAt: 100},
+		{pageLimit: 2, logAt: 100},
+		{pageLimit: -1, logAt: 100, workers: 3},
+		{pageLimit: 2, logAt: 100, workers: 3},
+	} {
+		for _, input := range []string{testDump, testXML} {
+			opts.checksum, err = readChecksum(sha1sums, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := loadXML(input, loadOptions{pageLimit: opts.pageLimit, logAt: 100})
+			result := loadXML(input, opts)
+			if !sameCounts(expect, result) {
+				t.Errorf(fsExp, expect, result)
+			}
+		}
+	}
+
+	// truncated input
+	c, err := readChecksum(sha1sums, testDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r = c.wrap(ioutil.NopCloser(bytes.NewReader(dump[:len(dump)-10])))
+	if err := c.verify(r, testDump); err == nil {
+		t.Errorf("expected checksum mismatch for truncated input")
+	}
+	r = c.wrap(ioutil.NopCloser(bytes.NewReader(dump)))
+	if err := c.verify(r, testDump); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
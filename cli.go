@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Subcommands, each with its own flags and help (<command> -h). Without a command, count is used, so that
+// the flags of earlier versions still work.
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"count", "count the words of a wikimedia dump file (the default command)", countCmd},
+	{"merge", "merge word frequency lists, summing the counts", mergeCmd},
+	{"diff", "compare two word frequency lists, sorted by keyness", diffCmd},
+	{"plot", "coverage and Zipf plot of a word frequency list", plotCmd},
+	{"query", "look up words in a word frequency list", queryCmd},
+	{"explain", "show how wikitext is cleaned up and split into words", explainCmd},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage() {
+	var lines []string
+	for _, c := range commands {
+		lines = append(lines, fmt.Sprintf("  %-8s %s", c.name, c.summary))
+	}
+	fmt.Fprintf(os.Stderr, `
+wstats is used for parsing wikimedia dump files on the fly into word frequency lists, and for working with the
+frequency lists.
+
+It is NOT ready for proper use, so use at your own risk.
+
+Usage:
+ $ go run . [count] <flags> <wikipedia dump path (file or url, xml or xml.bz2)>
+ $ go run . <command> <flags> <args>
+ $ go run . <command> -h (help for the command)
+
+Commands:
+%s
+
+`, strings.Join(lines, "\n"))
+}
+
+func main() {
+	var args = os.Args[1:]
+	if len(args) == 0 {
+		printUsage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if c, ok := findCommand(args[1]); ok {
+				c.run([]string{"-h"})
+				return
+			}
+		}
+		printUsage()
+		return
+	}
+	if c, ok := findCommand(args[0]); ok {
+		c.run(args[1:])
+		return
+	}
+	countCmd(args)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Export of the cleaned plain text corpus: the sentences counted for each page (and each counted revision), as
+// JSON Lines with the page id, revision id, title and text (one paragraph per line), or as plain text with one
+// sentence per line, the title first, and an empty line after each page. The sentences are the same as those
+// counted for the frequency lists (the title is counted as the first line of the text), so the corpus and the
+// frequency lists are consistent. The output is always gzip compressed.
+
+const (
+	corpusJSONL = "jsonl"
+	corpusText  = "text"
+)
+
+// corpusDoc is the text of a counted revision
+type corpusDoc struct {
+	ID       int    `json:"id"`
+	Revision int    `json:"revision"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+
+	titleSentences []string
+	sentences      []string
+}
+
+// newCorpusDoc returns the text of a revision, given its sentences. If hasTitle is set, the sentences of the first
+// line are the title.
+func newCorpusDoc(p Page, rev Revision, hasTitle bool, sentences []sentence) corpusDoc {
+	var doc = corpusDoc{ID: p.ID, Revision: rev.ID, Title: p.Title}
+	var paragraphs []string
+	var last = -1
+	for _, s := range sentences {
+		if hasTitle && s.line == 0 {
+			doc.titleSentences = append(doc.titleSentences, s.text)
+			continue
+		}
+		doc.sentences = append(doc.sentences, s.text)
+		if s.line != last {
+			paragraphs = append(paragraphs, s.text)
+			last = s.line
+		} else {
+			paragraphs[len(paragraphs)-1] += " " + s.text
+		}
+	}
+	doc.Text = strings.Join(paragraphs, "\n")
+	return doc
+}
+
+type corpusWriter struct {
+	format     string // corpusJSONL or corpusText
+	file       *os.File
+	gz         *gzip.Writer
+	buf        *bufio.Writer
+	nDocs      int
+	nSentences int
+	err        error // the first write error
+}
+
+func newCorpusWriter(path string, format string) (*corpusWriter, error) {
+	if format != corpusJSONL && format != corpusText {
+		return nil, fmt.Errorf("invalid corpus format: %s", format)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var cw = &corpusWriter{format: format, file: file, gz: gzip.NewWriter(file)}
+	cw.buf = bufio.NewWriter(cw.gz)
+	return cw, nil
+}
+
+func (cw *corpusWriter) write(docs []corpusDoc) {
+	for _, doc := range docs {
+		if cw.err != nil {
+			return
+		}
+		cw.nDocs++
+		cw.nSentences += len(doc.titleSentences) + len(doc.sentences)
+		if cw.format == corpusJSONL {
+			data, err := json.Marshal(doc)
+			if err != nil {
+				cw.err = err
+				return
+			}
+			_, cw.err = fmt.Fprintf(cw.buf, "%s\n", data)
+			continue
+		}
+		for _, s := range append(append([]string{}, doc.titleSentences...), doc.sentences...) {
+			fmt.Fprintln(cw.buf, s)
+		}
+		_, cw.err = fmt.Fprintln(cw.buf)
+	}
+}
+
+func (cw *corpusWriter) close() error {
+	var err = cw.err
+	if e := cw.buf.Flush(); err == nil {
+		err = e
+	}
+	if e := cw.gz.Close(); err == nil {
+		err = e
+	}
+	if e := cw.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewCorpusDoc(t *testing.T) {
+	var p = Page{ID: 7, Title: "Titel"}
+	var rev = Revision{ID: 11}
+	var sentences = []sentence{
+		{line: 0, text: "Titel"},
+		{line: 2, text: "En mening."},
+		{line: 2, text: "En till."},
+		{line: 4, text: "Ett nytt stycke"},
+	}
+	var doc = newCorpusDoc(p, rev, true, sentences)
+	var expect = corpusDoc{ID: 7, Revision: 11, Title: "Titel", Text: "En mening. En till.\nEtt nytt stycke",
+		titleSentences: []string{"Titel"}, sentences: []string{"En mening.", "En till.", "Ett nytt stycke"}}
+	if !reflect.DeepEqual(doc, expect) {
+		t.Errorf(fsExp, expect, doc)
+	}
+
+	// without a title, the first line is text
+	doc = newCorpusDoc(Page{ID: 7}, rev, false, sentences)
+	if expect := "Titel\nEn mening. En till.\nEtt nytt stycke"; doc.Text != expect {
+		t.Errorf(fsExp, expect, doc.Text)
+	}
+}
+
+// readCorpus returns the lines of a gzip compressed corpus file
+func readCorpus(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	var scanner = bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestLoadCorpus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{corpusJSONL, corpusText} {
+		var path = filepath.Join(dir, "corpus."+format+".gz")
+		cw, err := newCorpusWriter(path, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var opts = loadOptions{pageLimit: -1, logAt: 100, corpus: cw}
+		var result = loadXML(testXML, opts)
+		if err := cw.close(); err != nil {
+			t.Fatal(err)
+		}
+		var nSentences = 0
+		for _, n := range result.sentLengths {
+			nSentences += n
+		}
+		if cw.nDocs != result.nRevisions {
+			t.Errorf(fsExp, result.nRevisions, cw.nDocs)
+		}
+		if cw.nSentences != nSentences {
+			t.Errorf(fsExp, nSentences, cw.nSentences)
+		}
+
+		// the words of the exported text are the words counted
+		var wordFreqs = make(map[string]int)
+		var nDocs = 0
+		for _, line := range readCorpus(t, path) {
+			var texts = []string{line}
+			if format == corpusJSONL {
+				var doc corpusDoc
+				if err := json.Unmarshal([]byte(line), &doc); err != nil {
+					t.Fatal(err)
+				}
+				texts = append([]string{doc.Title}, strings.Split(doc.Text, "\n")...)
+				nDocs++
+			} else if line == "" {
+				nDocs++
+			}
+			for _, text := range texts {
+				for _, w := range result.tk.tokenizePlainLine(text) {
+					wordFreqs[w]++
+				}
+			}
+		}
+		if nDocs != result.nRevisions {
+			t.Errorf(fsExp, result.nRevisions, nDocs)
+		}
+		if !reflect.DeepEqual(wordFreqs, result.wordFreqs) {
+			t.Errorf("%s corpus: expected the same word counts as the frequency list", format)
+		}
+	}
+}
+
+func TestCorpusCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the corpus is compressed also without a .gz suffix
+	var path = filepath.Join(dir, "corpus.txt")
+	cw, err := newCorpusWriter(path, corpusText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw.write([]corpusDoc{{titleSentences: []string{"Titel"}, sentences: []string{"En mening."}}})
+	if err := cw.close(); err != nil {
+		t.Fatal(err)
+	}
+	if expect, result := "Titel En mening. ", strings.Join(readCorpus(t, path), " "); result != expect {
+		t.Errorf(fsExp, expect, result)
+	}
+}
+
+func TestCorpusFormat(t *testing.T) {
+	if _, err := newCorpusWriter(filepath.Join(os.TempDir(), "wstats-corpus"), "xml"); err == nil {
+		t.Errorf("expected an error for an invalid corpus format")
+	}
+}
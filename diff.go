@@ -0,0 +1,214 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The diff subcommand compares two frequency lists (e.g. two dumps of the same wiki, or two wikis), and reports the
+// keyness of each word: the log-likelihood G2 (Rayson & Garside, 2000), showing how significant the difference is,
+// and the log ratio (Hardie, 2014), showing how large it is (log2 of the ratio of the relative frequencies, positive
+// if the word is more frequent in the first list). The words are sorted by G2.
+
+const (
+	diffCommon   = "common"
+	diffNew      = "new"      // only in the first list
+	diffVanished = "vanished" // only in the second list
+)
+
+type keynessRow struct {
+	word     string
+	a, b     int     // counts
+	pmA, pmB float64 // counts per million words
+	g2       float64
+	logRatio float64
+	status   string
+}
+
+// logLikelihood returns G2 for the counts a and b, in corpora of sizes nA and nB
+func logLikelihood(a, b, nA, nB int) float64 {
+	var n = float64(nA + nB)
+	var eA = float64(nA) * float64(a+b) / n
+	var eB = float64(nB) * float64(a+b) / n
+	var g2 = 0.0
+	if a > 0 {
+		g2 += float64(a) * math.Log(float64(a)/eA)
+	}
+	if b > 0 {
+		g2 += float64(b) * math.Log(float64(b)/eB)
+	}
+	return 2 * g2
+}
+
+// logRatio returns log2 of the ratio of the relative frequencies, with zero counts replaced by 0.5
+func logRatio(a, b, nA, nB int) float64 {
+	var fa, fb = float64(a), float64(b)
+	if a == 0 {
+		fa = 0.5
+	}
+	if b == 0 {
+		fb = 0.5
+	}
+	return math.Log2((fa / float64(nA)) / (fb / float64(nB)))
+}
+
+// keyness aligns the lists, and returns the words with a combined count of at least minFreq and a G2 of at least
+// minG2, sorted by G2 (descending), and then by word. The corpus sizes are nA and nB.
+func keyness(a, b map[string]int, nA, nB int, minFreq int, minG2 float64) []keynessRow {
+	var result []keynessRow
+	var add = func(w string) {
+		var r = keynessRow{word: w, a: a[w], b: b[w], status: diffCommon}
+		if r.a+r.b < minFreq {
+			return
+		}
+		r.g2 = logLikelihood(r.a, r.b, nA, nB)
+		if r.g2 < minG2 {
+			return
+		}
+		r.logRatio = logRatio(r.a, r.b, nA, nB)
+		r.pmA = 1000000 * float64(r.a) / float64(nA)
+		r.pmB = 1000000 * float64(r.b) / float64(nB)
+		switch {
+		case r.b == 0:
+			r.status = diffNew
+		case r.a == 0:
+			r.status = diffVanished
+		}
+		result = append(result, r)
+	}
+	for w := range a {
+		add(w)
+	}
+	for w := range b {
+		if _, ok := a[w]; !ok {
+			add(w)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].g2 > result[j].g2 || (result[i].g2 == result[j].g2 && result[i].word < result[j].word)
+	})
+	return result
+}
+
+var diffColumns = []column{
+	{"word", "TEXT"}, {"count_a", "INTEGER"}, {"count_b", "INTEGER"}, {"per_million_a", "REAL"}, {"per_million_b", "REAL"},
+	{"g2", "REAL"}, {"log_ratio", "REAL"}, {"status", "TEXT"},
+}
+
+func writeKeyness(lw listWriter, rows []keynessRow) error {
+	for _, r := range rows {
+		if err := lw.writeRow(r.word, r.a, r.b, r.pmA, r.pmB, r.g2, r.logRatio, r.status); err != nil {
+			lw.close()
+			return err
+		}
+	}
+	return lw.close()
+}
+
+// readFreqMap reads a tsv frequency list into a map, and returns the sum of the counts
+func readFreqMap(path string) (map[string]int, int, error) {
+	var m = make(map[string]int)
+	var total = 0
+	err := readFreqs(path, func(key string, f int) {
+		m[key] += f
+		total += f
+	})
+	return m, total, err
+}
+
+func diffCmd(args []string) {
+	var usage = `
+wstats diff compares two frequency lists (tsv output of wstats), and reports the keyness of each word, sorted by
+log-likelihood (G2). The output columns are word, count and count per million words in each list, G2, log ratio
+(log2 of the ratio of the relative frequencies, positive if the word is more frequent in the first list), and
+status (common, new: only in the first list, or vanished: only in the second list).
+
+Usage:
+ $ go run . diff <flags> <word frequency list a> <word frequency list b>
+
+Cmd line flags:
+  -mf int          min freq: lower limit for the combined count of a word (optional, default = 0)
+  -g2 float        lower limit for G2, e.g. 3.84 for p < 0.05, or 15.13 for p < 0.0001 (optional, default = 0)
+  -totals string   corpus sizes <a>,<b>, if the lists are limited by min freq (optional, default = sum of the counts of each list)
+  -format string   output format: tsv, jsonl, csv (with header) or sqlite (optional, default = tsv)
+  -out string      output file (optional, default = standard out, required for sqlite)
+  -h(elp)          help: print help message
+
+Example usage:
+  $ go run . diff -mf 10 -g2 15.13 svwiki-latest.freq svwiki-20170101.freq
+  $ go run . diff -format csv -out sv-no.csv svwiki.freq nowiki.freq
+
+`
+	var f = flag.NewFlagSet("diff", flag.ExitOnError)
+	var minFreq = f.Int("mf", 0, "min freq")
+	var minG2 = f.Float64("g2", 0, "min G2")
+	var totals = f.String("totals", "", "corpus sizes")
+	var format = f.String("format", formatTSV, "output format")
+	var outFile = f.String("out", "", "output file")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage)
+	}
+	if err := f.Parse(args); err != nil || len(f.Args()) != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if !contains(outputFormats, *format) || *format == formatParquet {
+		log.Fatal("Invalid output format: ", *format)
+	}
+	if *format == formatSQLite && *outFile == "" {
+		log.Fatal("-format sqlite requires an output file (-out)")
+	}
+
+	a, nA, err := readFreqMap(f.Args()[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, nB, err := readFreqMap(f.Args()[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *totals != "" {
+		fs := strings.Split(*totals, ",")
+		if len(fs) != 2 {
+			log.Fatal("Invalid corpus sizes: ", *totals)
+		}
+		if nA, err = strconv.Atoi(fs[0]); err != nil {
+			log.Fatal("Invalid corpus sizes: ", *totals)
+		}
+		if nB, err = strconv.Atoi(fs[1]); err != nil {
+			log.Fatal("Invalid corpus sizes: ", *totals)
+		}
+	}
+	if nA <= 0 || nB <= 0 {
+		log.Fatal("Empty frequency list")
+	}
+
+	var rows = keyness(a, b, nA, nB, *minFreq, *minG2)
+	lw, err := newListWriter(*format, *outFile, "diff", diffColumns)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := writeKeyness(lw, rows); err != nil {
+		log.Fatal(err)
+	}
+	var nNew, nVanished = 0, 0
+	for _, r := range rows {
+		switch r.status {
+		case diffNew:
+			nNew++
+		case diffVanished:
+			nVanished++
+		}
+	}
+	log.Print("No. of words a       : ", lIntPrettyPrint(nA))
+	log.Print("No. of words b       : ", lIntPrettyPrint(nB))
+	log.Print("No. of rows          : ", lIntPrettyPrint(len(rows)))
+	log.Print("- new words          : ", lIntPrettyPrint(nNew))
+	log.Print("- vanished words     : ", lIntPrettyPrint(nVanished))
+}
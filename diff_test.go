@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestLogLikelihood(t *testing.T) {
+	var tests = []struct {
+		a, b, nA, nB int
+		g2, logRatio float64
+	}{
+		{10, 0, 1000, 1000, 20 * math.Log(2), math.Log2(20)},
+		{0, 10, 1000, 1000, 20 * math.Log(2), -math.Log2(20)},
+		{5, 5, 1000, 1000, 0, 0},
+		{10, 10, 1000, 2000, 2.3557, 1},
+	}
+	for _, test := range tests {
+		g2 := logLikelihood(test.a, test.b, test.nA, test.nB)
+		lr := logRatio(test.a, test.b, test.nA, test.nB)
+		if math.Abs(g2-test.g2) > 0.0001 || math.Abs(lr-test.logRatio) > 0.0001 {
+			t.Errorf(fsExp, fmt.Sprintf("%.4f %.4f", test.g2, test.logRatio), fmt.Sprintf("%.4f %.4f", g2, lr))
+		}
+	}
+}
+
+func TestKeyness(t *testing.T) {
+	var a = map[string]int{"och": 50, "i": 30, "twitter": 15, "att": 5}
+	var b = map[string]int{"och": 50, "i": 30, "myspace": 15, "att": 5}
+
+	var tests = []struct {
+		minFreq int
+		minG2   float64
+		expect  string
+	}{
+		{0, 0, "[myspace:0/15:vanished twitter:15/0:new att:5/5:common i:30/30:common och:50/50:common]"},
+		{20, 0, "[i:30/30:common och:50/50:common]"},
+		{0, 3.84, "[myspace:0/15:vanished twitter:15/0:new]"},
+	}
+	for _, test := range tests {
+		var result []string
+		for _, r := range keyness(a, b, 100, 100, test.minFreq, test.minG2) {
+			result = append(result, fmt.Sprintf("%s:%d/%d:%s", r.word, r.a, r.b, r.status))
+		}
+		if fmt.Sprint(result) != test.expect {
+			t.Errorf(fsExp, test.expect, result)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+)
+
+// Dispersion measures, showing how evenly a word is distributed over the corpus. The pages are divided into
+// a number of corpus parts (page no. modulo the number of parts), and the word frequencies are counted per part.
+// See Gries (2008), Dispersions and adjusted frequencies in corpora, International Journal of Corpus Linguistics 13(4).
+
+// partFreqs are the frequencies of a word in each corpus part
+type partFreqs []int32
+
+// juillandsD returns Juilland's D (0 = all occurrences in one part, 1 = evenly distributed), using the relative
+// frequencies of the word in each part, since the parts are not of equal size
+func juillandsD(freqs partFreqs, partSizes []int) float64 {
+	var n = 0
+	var sum, sumSq float64
+	for i, size := range partSizes {
+		if size == 0 {
+			continue
+		}
+		n++
+		if i < len(freqs) {
+			p := float64(freqs[i]) / float64(size)
+			sum += p
+			sumSq += p * p
+		}
+	}
+	if n < 2 || sum == 0 {
+		return 0
+	}
+	var mean = sum / float64(n)
+	var variance = sumSq/float64(n) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	var d = 1 - (math.Sqrt(variance)/mean)/math.Sqrt(float64(n-1))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// griesDP returns Gries' deviation of proportions (0 = distributed as the part sizes, close to 1 = all occurrences in
+// one small part)
+func griesDP(freqs partFreqs, partSizes []int) float64 {
+	var f, total float64
+	for i, size := range partSizes {
+		total += float64(size)
+		if i < len(freqs) {
+			f += float64(freqs[i])
+		}
+	}
+	if f == 0 || total == 0 {
+		return 0
+	}
+	var sum float64
+	for i, size := range partSizes {
+		var v float64
+		if i < len(freqs) {
+			v = float64(freqs[i])
+		}
+		sum += math.Abs(v/f - float64(size)/total)
+	}
+	return sum / 2
+}
+
+// addDispersion adds the word frequencies of a page to the corpus part of the page
+func (result *loadResult) addDispersion(wordFreqs map[string]int) {
+	var nParts = len(result.partSizes)
+	var part = (result.nPages - 1) % nParts
+	for w, f := range wordFreqs {
+		pf, ok := result.partFreqs[w]
+		if !ok {
+			pf = make(partFreqs, nParts)
+			result.partFreqs[w] = pf
+		}
+		pf[part] += int32(f)
+		result.partSizes[part] += f
+	}
+}
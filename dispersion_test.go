@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDispersion(t *testing.T) {
+	var tests = []struct {
+		freqs     partFreqs
+		partSizes []int
+		d         float64
+		dp        float64
+	}{
+		{partFreqs{2, 2, 2, 2}, []int{10, 10, 10, 10}, 1, 0},
+		{partFreqs{8, 0, 0, 0}, []int{10, 10, 10, 10}, 0, 0.75},
+		{partFreqs{1, 2, 3, 4, 5}, []int{10, 10, 10, 10, 10}, 0.7643, 0.2},
+		{partFreqs{1, 2, 0}, []int{10, 20, 0}, 1, 0},
+		{partFreqs{0, 0}, []int{10, 10}, 0, 0},
+	}
+	for _, test := range tests {
+		if d := juillandsD(test.freqs, test.partSizes); math.Abs(d-test.d) > 0.0001 {
+			t.Errorf("Juilland's D for %v: "+fsExp, test.freqs, test.d, d)
+		}
+		if dp := griesDP(test.freqs, test.partSizes); math.Abs(dp-test.dp) > 0.0001 {
+			t.Errorf("Gries' DP for %v: "+fsExp, test.freqs, test.dp, dp)
+		}
+	}
+}
+
+func TestLoadDispersion(t *testing.T) {
+	var opts = loadOptions{pageLimit: -1, logAt: 100, dispersionParts: 3, docFreqs: true, workers: 1}
+	result := loadXML(testXML, opts)
+	var expect = map[string]int{"är": 4, "ateism": 2, "jakarta": 1}
+	for w, df := range expect {
+		if result.docFreqs[w] != df {
+			t.Errorf("document frequency for %s: "+fsExp, w, df, result.docFreqs[w])
+		}
+	}
+	var total = 0
+	for _, size := range result.partSizes {
+		total += size
+	}
+	if total != result.nWords {
+		t.Errorf(fsExp, result.nWords, total)
+	}
+
+	opts.workers = 3
+	par := loadXML(testDump, opts)
+	if !sameCounts(result, par) {
+		t.Errorf(fsExp, result, par)
+	}
+}
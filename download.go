@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Downloading of dump files over http. A dropped connection is resumed from the current position using a range
+// request, with a number of retries and an exponential backoff. A connection that stalls (no response, or no data
+// for the timeout) is handled as a dropped connection. The downloaded data can also be saved to a cache
+// directory (see -cache), so that the same file is read from disk on the next run.
+
+type downloadOptions struct {
+	retries  int           // max no. of consecutive retries
+	backoff  time.Duration // wait before the first retry, doubled for each consecutive retry
+	timeout  time.Duration // max wait for a response, or for data from an open connection (no timeout if 0)
+	cacheDir string        // directory for cached downloads (no caching if empty)
+}
+
+var download = downloadOptions{retries: 5, backoff: time.Second, timeout: time.Minute}
+
+// errStalled is returned by a read that gets no data for the timeout
+type errStalled time.Duration
+
+func (e errStalled) Error() string {
+	return fmt.Sprintf("no data received in %v", time.Duration(e))
+}
+
+// httpReader reads a url, starting at offset, and reconnects on errors
+type httpReader struct {
+	url       string
+	opts      downloadOptions
+	offset    int64  // current position
+	end       int64  // end position (exclusive), -1 for end of file
+	validator string // ETag or Last-Modified of the file, sent with If-Range
+	restart   bool   // on the first request, accept the whole file if it has changed (offset is then set to 0)
+	body      io.ReadCloser
+	cancel    context.CancelFunc // cancels the current request
+	stalled   *time.Timer        // cancels the current request when it stalls (nil if no timeout)
+	failures  int                // no. of consecutive failures
+}
+
+// openURL opens the url at the byte offset, for length bytes (-1 for the rest of the file)
+func openURL(url string, offset int64, length int64, opts downloadOptions) (io.ReadCloser, error) {
+	var r = &httpReader{url: url, opts: opts, offset: offset, end: -1}
+	if length >= 0 {
+		r.end = offset + length
+	}
+	return r, r.open()
+}
+
+// open sends the first request, with retries
+func (r *httpReader) open() error {
+	for {
+		retry, err := r.connect()
+		if err == nil {
+			return nil
+		}
+		if !retry || !r.wait(err) {
+			return err
+		}
+	}
+}
+
+// connect sends a request for the data from the current position. If the request fails, retry is true for errors
+// that may be temporary.
+func (r *httpReader) connect() (retry bool, err error) {
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return false, err
+	}
+	var ranged = r.offset > 0 || r.end >= 0
+	if r.end >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.offset, r.end-1))
+	} else if r.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	}
+	if r.validator != "" {
+		// if the file has changed since the first request, the server will send the whole (new) file instead of the range
+		req.Header.Set("If-Range", r.validator)
+	}
+	var ctx context.Context
+	ctx, r.cancel = context.WithCancel(context.Background())
+	if r.opts.timeout > 0 {
+		r.stalled = time.AfterFunc(r.opts.timeout, r.cancel)
+	}
+	response, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if fired := !r.stop(); err != nil {
+		r.cancel()
+		if fired {
+			err = errStalled(r.opts.timeout)
+		}
+		return true, err
+	}
+	var expect = http.StatusOK
+	if ranged {
+		expect = http.StatusPartialContent
+	}
+	if ranged && r.restart && r.validator != "" && response.StatusCode == http.StatusOK {
+		// the file has changed: read it from the start
+		r.offset = 0
+		expect = http.StatusOK
+	}
+	if response.StatusCode != expect {
+		response.Body.Close()
+		r.cancel()
+		if response.StatusCode >= 500 {
+			return true, fmt.Errorf("%s %s", response.Status, r.url)
+		}
+		if ranged && response.StatusCode == http.StatusOK {
+			return false, fmt.Errorf("%s %s (range requests not supported, or the file has changed)", response.Status, r.url)
+		}
+		return false, fmt.Errorf("%s %s", response.Status, r.url)
+	}
+	if r.validator == "" || expect == http.StatusOK {
+		r.validator = responseValidator(response)
+	}
+	r.restart = false
+	r.body = response.Body
+	return false, nil
+}
+
+// stop stops the stall timer, and returns false if it has already fired
+func (r *httpReader) stop() bool {
+	return r.stalled == nil || r.stalled.Stop()
+}
+
+// responseValidator returns the strong ETag of the response, or else the Last-Modified date, for If-Range
+func responseValidator(response *http.Response) string {
+	if etag := response.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return response.Header.Get("Last-Modified")
+}
+
+// wait logs the error and sleeps before the next retry, or returns false if there are no retries left
+func (r *httpReader) wait(err error) bool {
+	if r.failures >= r.opts.retries {
+		return false
+	}
+	var d = r.opts.backoff << uint(r.failures)
+	r.failures++
+	log.Printf("Download error at offset %d: %v (retry %d of %d in %v)", r.offset, err, r.failures, r.opts.retries, d)
+	time.Sleep(d)
+	return true
+}
+
+func (r *httpReader) Read(p []byte) (int, error) {
+	for {
+		if r.end >= 0 && r.offset >= r.end {
+			return 0, io.EOF
+		}
+		if r.body == nil {
+			retry, err := r.connect()
+			if err != nil {
+				if !retry || !r.wait(err) {
+					return 0, err
+				}
+				continue
+			}
+		}
+		if r.stalled != nil {
+			r.stalled.Reset(r.opts.timeout)
+		}
+		n, err := r.body.Read(p)
+		var fired = !r.stop()
+		r.offset += int64(n)
+		if n > 0 {
+			r.failures = 0
+		}
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if fired {
+			err = errStalled(r.opts.timeout)
+		}
+		// a body shorter than its content length gives io.ErrUnexpectedEOF
+		r.closeBody()
+		if !r.wait(err) {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// closeBody closes the body of the current request
+func (r *httpReader) closeBody() error {
+	var err = r.body.Close()
+	r.cancel()
+	r.body = nil
+	return err
+}
+
+func (r *httpReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.closeBody()
+}
+
+// start: cache
+
+// cachePath returns the path of the cached copy of the url
+func (opts downloadOptions) cachePath(rawURL string) string {
+	var name = path.Base(rawURL)
+	if u, err := url.Parse(rawURL); err == nil {
+		name = path.Base(u.Path)
+		if u.RawQuery != "" {
+			name = fmt.Sprintf("%s-%x", name, sha1.Sum([]byte(rawURL)))
+		}
+	}
+	return filepath.Join(opts.cacheDir, name)
+}
+
+// cached returns the path of the cached copy of the url, if the url has been downloaded completely, otherwise the url
+func (opts downloadOptions) cached(rawURL string) string {
+	if opts.cacheDir == "" {
+		return rawURL
+	}
+	var p = opts.cachePath(rawURL)
+	if _, err := os.Stat(p); err == nil {
+		return p
+	}
+	return rawURL
+}
+
+// cachingReader saves the downloaded data to a partial cache file, that is renamed when the download is complete
+type cachingReader struct {
+	io.Reader
+	download io.ReadCloser
+	part     *os.File
+	path     string
+}
+
+// openCachedURL opens the url, saving the data in the cache directory. A partial cache file from an earlier
+// run is read first, and the download continues from the end of it, if the file has not changed since (by the
+// ETag or Last-Modified date saved with the partial file). Otherwise, the download starts over.
+func openCachedURL(url string, opts downloadOptions) (io.ReadCloser, error) {
+	var p = opts.cachePath(url)
+	if err := os.MkdirAll(opts.cacheDir, 0755); err != nil {
+		return nil, err
+	}
+	part, err := os.OpenFile(p+".part", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	size, err := part.Seek(0, io.SeekEnd)
+	if err != nil {
+		part.Close()
+		return nil, err
+	}
+	validator, err := ioutil.ReadFile(p + ".part.validator")
+	if size > 0 && (err != nil || len(validator) == 0) {
+		log.Printf("Restarting download of %s (no ETag or Last-Modified date saved for the %d cached bytes)", url, size)
+		size = 0
+	}
+	var dl = &httpReader{url: url, opts: opts, offset: size, end: -1, restart: true}
+	if size > 0 {
+		dl.validator = string(validator)
+	}
+	if err := dl.open(); err != nil {
+		part.Close()
+		return nil, err
+	}
+	if size > 0 && dl.offset == 0 {
+		log.Printf("Restarting download of %s (the file has changed since the %d cached bytes)", url, size)
+		size = 0
+	} else if size > 0 {
+		log.Printf("Continuing download of %s after %d cached bytes", url, size)
+	}
+	if size == 0 {
+		if err := part.Truncate(0); err != nil {
+			dl.Close()
+			part.Close()
+			return nil, err
+		}
+		if _, err := part.Seek(0, io.SeekStart); err != nil {
+			dl.Close()
+			part.Close()
+			return nil, err
+		}
+	}
+	if err := ioutil.WriteFile(p+".part.validator", []byte(dl.validator), 0644); err != nil {
+		dl.Close()
+		part.Close()
+		return nil, err
+	}
+	var r = &cachingReader{download: dl, part: part, path: p}
+	r.Reader = io.MultiReader(io.NewSectionReader(part, 0, size), io.TeeReader(dl, part))
+	return r, nil
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF && r.part != nil {
+		err2 := r.part.Close()
+		r.part = nil
+		if err2 == nil {
+			err2 = os.Rename(r.path+".part", r.path)
+		}
+		if err2 == nil {
+			os.Remove(r.path + ".part.validator")
+		}
+		if err2 != nil {
+			return n, err2
+		}
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	if r.part != nil {
+		r.part.Close()
+	}
+	return r.download.Close()
+}
+
+// end: cache
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer serves the data, supporting range requests (and If-Range), but drops the connection after sending
+// dropAfter bytes of a response, for the first drops responses. If hang is set, the connection stalls instead of
+// being dropped (before the response if dropAfter is negative), until the client gives up.
+type flakyServer struct {
+	data      []byte
+	etag      string // "test" if not set
+	dropAfter int
+	hang      bool
+	mutex     sync.Mutex
+	drops     int
+	requests  int
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests++
+	var drop = s.drops > 0
+	if drop {
+		s.drops--
+	}
+	s.mutex.Unlock()
+	if drop && s.hang && s.dropAfter < 0 {
+		<-r.Context().Done()
+		return
+	}
+
+	var etag = s.etag
+	if etag == "" {
+		etag = `"test"`
+	}
+	var from, to = 0, len(s.data) - 1
+	var status = http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" && (r.Header.Get("If-Range") == "" || r.Header.Get("If-Range") == etag) {
+		fs := strings.SplitN(strings.TrimPrefix(rng, "bytes="), "-", 2)
+		from, _ = strconv.Atoi(fs[0])
+		if fs[1] != "" {
+			to, _ = strconv.Atoi(fs[1])
+		}
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", from, to, len(s.data)))
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Length", strconv.Itoa(to-from+1))
+	w.WriteHeader(status)
+	var body = s.data[from : to+1]
+	if drop && len(body) > s.dropAfter {
+		// writing less than the content length makes the server close the connection
+		body = body[:s.dropAfter]
+		if s.hang {
+			w.Write(body)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+	}
+	w.Write(body)
+}
+
+var testDownloadOptions = downloadOptions{retries: 3, backoff: time.Millisecond, timeout: 100 * time.Millisecond}
+
+func TestDownloadRetries(t *testing.T) {
+	data, err := ioutil.ReadFile(testDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		offset    int64
+		length    int64
+		drops     int
+		dropAfter int
+		hang      bool
+		ok        bool
+	}{
+		{0, -1, 0, 0, false, true},
+		{0, -1, 5, 300, false, true}, // retries are counted from the last successful read
+		{0, -1, 3, 0, false, true},
+		{0, -1, 4, 0, false, false},
+		{544, -1, 3, 300, false, true},
+		{544, 662, 2, 300, false, true},
+		// stalled connections, before the response or after some data
+		{0, -1, 3, -1, true, true},
+		{0, -1, 4, -1, true, false},
+		{0, -1, 5, 300, true, true},
+		{544, 662, 2, 300, true, true},
+	}
+	for _, test := range tests {
+		var fs = &flakyServer{data: data, dropAfter: test.dropAfter, drops: test.drops, hang: test.hang}
+		server := httptest.NewServer(fs)
+
+		var expect = data[test.offset:]
+		if test.length >= 0 {
+			expect = expect[:test.length]
+		}
+		var result []byte
+		r, err := openURL(server.URL+"/test.xml.bz2", test.offset, test.length, testDownloadOptions)
+		if err == nil {
+			result, err = ioutil.ReadAll(r)
+			r.Close()
+		}
+		server.Close()
+		if test.ok && err != nil {
+			t.Errorf("unexpected error for %v: %v", test, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("expected error for %v", test)
+		} else if test.ok && !bytes.Equal(result, expect) {
+			t.Errorf("unexpected data for %v: expected %d bytes, got %d", test, len(expect), len(result))
+		}
+	}
+}
+
+func TestDownloadCache(t *testing.T) {
+	data, err := ioutil.ReadFile(testDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var fs = &flakyServer{data: data, dropAfter: 300}
+	server := httptest.NewServer(fs)
+	defer server.Close()
+	var url = server.URL + "/svwiki-test-pages-articles-multistream.xml.bz2"
+	var opts = testDownloadOptions
+	opts.cacheDir = dir
+
+	// interrupted download, continued on the next run
+	r, err := openCachedURL(url, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf = make([]byte, 1000)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if opts.cached(url) != url {
+		t.Errorf("expected no cached file after an interrupted download")
+	}
+
+	fs.drops = 2
+	r, err = openCachedURL(url, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result, data) {
+		t.Errorf("unexpected data: expected %d bytes, got %d", len(data), len(result))
+	}
+
+	cached := opts.cached(url)
+	if cached == url {
+		t.Fatalf("expected a cached file")
+	}
+	cachedData, err := ioutil.ReadFile(cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cachedData, data) {
+		t.Errorf("unexpected cached data: expected %d bytes, got %d", len(data), len(cachedData))
+	}
+
+	// the cached file is used instead of the url
+	download.cacheDir = dir
+	defer func() { download.cacheDir = "" }()
+	var requests = fs.requests
+	var lOpts = loadOptions{pageLimit: -1, logAt: 100}
+	if expect, res := loadXML(testDump, lOpts), loadXML(url, lOpts); !sameCounts(expect, res) {
+		t.Errorf(fsExp, expect, res)
+	}
+	if fs.requests != requests {
+		t.Errorf("expected no requests for a cached file, got %d", fs.requests-requests)
+	}
+}
+
+func TestDownloadCacheChanged(t *testing.T) {
+	data, err := ioutil.ReadFile(testDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var fs = &flakyServer{data: data, etag: `"v1"`, dropAfter: 300, drops: 1}
+	server := httptest.NewServer(fs)
+	defer server.Close()
+	var url = server.URL + "/svwiki-latest-pages-articles-multistream.xml.bz2"
+	var opts = testDownloadOptions
+	opts.retries = 0
+	opts.cacheDir = dir
+
+	var readAll = func() []byte {
+		r, err := openCachedURL(url, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		result, _ := ioutil.ReadAll(r)
+		return result
+	}
+
+	// an interrupted download, and a new version of the file on the next run: the download starts over
+	if result := readAll(); len(result) != 300 {
+		t.Errorf(fsExp, 300, len(result))
+	}
+	var newData = append([]byte("new version\n"), data...)
+	fs.data = newData
+	fs.etag = `"v2"`
+	if result := readAll(); !bytes.Equal(result, newData) {
+		t.Errorf("unexpected data: expected %d bytes, got %d", len(newData), len(result))
+	}
+	cachedData, err := ioutil.ReadFile(opts.cached(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cachedData, newData) {
+		t.Errorf("unexpected cached data: expected %d bytes, got %d", len(newData), len(cachedData))
+	}
+	if _, err := os.Stat(opts.cachePath(url) + ".part.validator"); err == nil {
+		t.Errorf("expected no validator file after a complete download")
+	}
+
+	// a partial file without validator is not continued
+	os.Remove(opts.cachePath(url))
+	if err := ioutil.WriteFile(opts.cachePath(url)+".part", []byte("old version"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result := readAll(); !bytes.Equal(result, newData) {
+		t.Errorf("unexpected data: expected %d bytes, got %d", len(newData), len(result))
+	}
+}
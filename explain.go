@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// The explain subcommand shows how the wikitext of a page is cleaned up and split into words, to help when
+// debugging the cleanup rules.
+
+// explain writes the steps of the tokenization of the text: for the wikitext parser, the plain text and the
+// words of each sentence; for the line based parser, the words of each line, or that the line is skipped.
+// The word counts are the same as for tokenizeText.
+func (tk *tokenizer) explain(w io.Writer, text string) error {
+	var buf = bufio.NewWriter(w)
+	if tk.opts.parser != parserLines {
+		fmt.Fprintln(buf, "# plain text")
+		for _, line := range strings.Split(tk.parseWikitext(text), "\n") {
+			if strings.TrimSpace(line) != "" {
+				fmt.Fprintln(buf, line)
+			}
+		}
+	} else {
+		fmt.Fprintln(buf, "# lines")
+		for _, l0 := range strings.Split(text, "\n") {
+			line := tk.preFilterLine(l0)
+			if tk.skip(line) {
+				fmt.Fprintf(buf, "skip\t%s\n", line)
+			} else {
+				fmt.Fprintf(buf, "keep\t%s\n", line)
+			}
+		}
+	}
+
+	nLines, nLinesSkipped, sentences := tk.tokenizeSentences(text)
+	fmt.Fprintln(buf, "# sentences")
+	for i, words := range sentences {
+		fmt.Fprintf(buf, "%d\t%s\n", i+1, strings.Join(words, " | "))
+	}
+	var wordFreqs = countWords(sentences)
+	var nWords = 0
+	fmt.Fprintln(buf, "# words")
+	for _, pair := range sortByWordCount(wordFreqs) {
+		nWords += pair.Value
+		fmt.Fprintf(buf, "%d\t%s\n", pair.Value, pair.Key)
+	}
+	fmt.Fprintf(buf, "# %d lines, %d skipped lines, %d words, %d unique words\n", nLines, nLinesSkipped, nWords, len(wordFreqs))
+	return buf.Flush()
+}
+
+func explainCmd(args []string) {
+	var usage = `
+wstats explain shows how wikitext is cleaned up and split into words: the plain text (or the lines kept and
+skipped, for the line based parser), the words of each sentence, and the word counts. The wikitext is read from
+a file, or from standard in.
+
+Usage:
+ $ go run . explain <flags> <wikitext file>
+
+Cmd line flags:
+  -parser string      wikitext parser: wikitext (full parser) or lines (line based regexps) (optional, default = wikitext)
+  -tokenizer string   word tokenizer: regexp (punctuation regexps) or uax29 (Unicode word boundaries) (optional, default = regexp)
+  -lang string        language profile for the tokenizer: generic, en, fi, fr or sv (optional, default = generic)
+  -case               keep the case of the words (optional)
+  -punktmodel string  punkt model file (JSON, see count -punktmodel), for the punkt sentence splitter (optional)
+  -nsaliases string   namespace aliases (file or url), in MediaWiki api json format (optional)
+  -magicwords string  magic words (file or url), in MediaWiki api json format, for the local names of image options (optional)
+  -text string        wikitext to explain, instead of a file (optional)
+  -h(elp)             help: print help message
+
+Example usage:
+  $ go run . explain page.wikitext
+  $ go run . explain -parser lines -text "'''Stockholm''' är [[Sveriges]] huvudstad."
+
+`
+	var f = flag.NewFlagSet("explain", flag.ExitOnError)
+	var parser = f.String("parser", parserWikitext, "wikitext parser")
+	var wordTokenizer = f.String("tokenizer", tokenizerRegexp, "word tokenizer")
+	var lang = f.String("lang", langGeneric, "language profile")
+	var keepCase = f.Bool("case", false, "keep case")
+	var punktModel = f.String("punktmodel", "", "punkt model file")
+	var nsAliases = f.String("nsaliases", "", "namespace aliases")
+	var magicWords = f.String("magicwords", "", "magic words")
+	var text = f.String("text", "", "wikitext")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage)
+	}
+	if err := f.Parse(args); err != nil || len(f.Args()) > 1 || (*text != "" && len(f.Args()) > 0) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if *parser != parserWikitext && *parser != parserLines {
+		log.Fatal("Invalid parser: ", *parser)
+	}
+	if *wordTokenizer != tokenizerRegexp && *wordTokenizer != tokenizerUAX29 {
+		log.Fatal("Invalid tokenizer: ", *wordTokenizer)
+	}
+	if _, ok := langProfiles[*lang]; !ok {
+		log.Fatal("Invalid language profile: ", *lang)
+	}
+	var si = defaultSiteInfo
+	if *nsAliases != "" {
+		aliases, err := readNamespaceAliases(*nsAliases)
+		if err != nil {
+			log.Fatal(err)
+		}
+		si.Aliases = append(si.Aliases, aliases...)
+	}
+	if *magicWords != "" {
+		imageOptions, err := readMagicWords(*magicWords)
+		if err != nil {
+			log.Fatal(err)
+		}
+		si.ImageOptions = append(si.ImageOptions, imageOptions...)
+	}
+	if *text == "" {
+		var r io.Reader = os.Stdin
+		if len(f.Args()) == 1 {
+			file, err := os.Open(f.Args()[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer file.Close()
+			r = file
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*text = string(data)
+	}
+	var tkOpts = tokenizerOptions{parser: *parser, tokenizer: *wordTokenizer, lang: *lang, keepCase: *keepCase}
+	if *punktModel != "" {
+		m, err := readPunktModel(*punktModel)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tkOpts.sentences = sentencesPunkt
+		tkOpts.punkt = m
+	}
+	var tk = newTokenizer(si, tkOpts)
+	if err := tk.explain(os.Stdout, *text); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	var text = "'''Stockholm''' är [[Sverige]]s huvudstad. Den är stor.\n{| class=wikitable\n| x\n|}"
+	var tests = []struct {
+		parser string
+		expect []string
+	}{
+		{parserWikitext, []string{
+			"# plain text\nStockholm är Sveriges huvudstad. Den är stor.\n",
+			"# sentences\n1\tstockholm | är | sveriges | huvudstad\n2\tden | är | stor\n",
+			"# words\n2\tär\n",
+			"# 4 lines, 3 skipped lines, 7 words, 6 unique words\n",
+		}},
+		{parserLines, []string{
+			"keep\t'''Stockholm''' är [[Sverige]]s huvudstad. Den är stor.\nskip\t{| class=wikitable\nskip\t| x\nskip\t|}\n",
+			"# sentences\n1\tstockholm | är | sveriges | huvudstad | den | är | stor\n",
+			"# 4 lines, 3 skipped lines, 7 words, 6 unique words\n",
+		}},
+	}
+	for _, test := range tests {
+		var tk = newTokenizer(defaultSiteInfo, tokenizerOptions{parser: test.parser})
+		var buf bytes.Buffer
+		if err := tk.explain(&buf, text); err != nil {
+			t.Fatal(err)
+		}
+		for _, expect := range test.expect {
+			if !strings.Contains(buf.String(), expect) {
+				t.Errorf(fsExp, expect, buf.String())
+			}
+		}
+	}
+}
@@ -1,3 +1,10 @@
 module github.com/stts-se/wstats
 
 go 1.12
+
+require (
+	github.com/rivo/uniseg v0.4.4
+	github.com/xitongsys/parquet-go v1.6.2
+	golang.org/x/text v0.3.8
+	modernc.org/sqlite v1.20.4
+)
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Pages with several revisions, as in the pages-meta-history dumps. Either all revisions of a page are counted, or
+// only the latest revision. With time buckets (year or month), the counts are also split by revision timestamp,
+// into a word frequency time series:
+//   - for all revisions, the counts of each bucket are for the revisions made during that year or month
+//   - for the latest revision, the counts of each bucket are for the wiki as it was at the end of that year or
+//     month (the latest revision of each page at that time), so the last bucket has the same counts as the word
+//     frequency list. The counts are collected as changes (deltas) per bucket, and summed when writing the output.
+// The revisions of a page are decoded and counted one at a time (see pageCounter), so a page with many revisions
+// is not held in memory.
+
+const (
+	revisionsAll    = "all"
+	revisionsLatest = "latest"
+
+	bucketYear  = "year"
+	bucketMonth = "month"
+)
+
+// Revision is used for xml parsing (see Page)
+type Revision struct {
+	ID        int    `xml:"id"`
+	Timestamp string `xml:"timestamp"`
+	Text      string `xml:"text"`
+}
+
+// bucketFreqs are the word counts per time bucket
+type bucketFreqs map[string]map[string]int
+
+func (bf bucketFreqs) add(bucket string, freqs map[string]int, sign int) {
+	m, ok := bf[bucket]
+	if !ok {
+		m = make(map[string]int)
+		bf[bucket] = m
+	}
+	for w, f := range freqs {
+		m[w] += sign * f
+	}
+}
+
+// timeBucket returns the bucket of a revision timestamp (yyyy or yyyy-mm), or false for an invalid timestamp
+func timeBucket(timestamp string, size string) (string, bool) {
+	if len(timestamp) < 7 || timestamp[4] != '-' {
+		return "", false
+	}
+	if size == bucketYear {
+		return timestamp[:4], true
+	}
+	return timestamp[:7], true
+}
+
+// nextBucket returns the bucket following b
+func nextBucket(b string) string {
+	year, _ := strconv.Atoi(b[:4])
+	if len(b) == 4 {
+		return fmt.Sprintf("%04d", year+1)
+	}
+	month, _ := strconv.Atoi(b[5:7])
+	if month == 12 {
+		return fmt.Sprintf("%04d-01", year+1)
+	}
+	return fmt.Sprintf("%04d-%02d", year, month+1)
+}
+
+func (result *loadResult) addBuckets(bf bucketFreqs) {
+	for b, freqs := range bf {
+		result.bucketFreqs.add(b, freqs, 1)
+	}
+}
+
+// writeTimeSeries writes the word frequency time series (limited by min freq), with the rows sorted by bucket, and
+// then by frequency. For the latest revision, the changes are summed, and every bucket from the first to the last
+// is written. It returns the number of buckets.
+func writeTimeSeries(result loadResult, opts loadOptions, out outputOptions) (int, error) {
+	var path = out.timeFile
+	if out.format == formatSQLite {
+		path = out.file
+	}
+	lw, err := newListWriter(out.format, path, "timeseries", []column{{"bucket", "TEXT"}, {"count", "INTEGER"}, {"word", "TEXT"}})
+	if err != nil {
+		return 0, err
+	}
+	var buckets []string
+	for b := range result.bucketFreqs {
+		buckets = append(buckets, b)
+	}
+	sort.Strings(buckets)
+	if opts.revisions == revisionsLatest && len(buckets) > 0 {
+		var all []string
+		for b := buckets[0]; b <= buckets[len(buckets)-1]; b = nextBucket(b) {
+			all = append(all, b)
+		}
+		buckets = all
+	}
+	var current = make(map[string]int)
+	for _, b := range buckets {
+		if opts.revisions == revisionsLatest {
+			for w, f := range result.bucketFreqs[b] {
+				if current[w]+f == 0 {
+					delete(current, w)
+				} else {
+					current[w] += f
+				}
+			}
+		} else {
+			current = result.bucketFreqs[b]
+		}
+		for _, pair := range sortByWordCount(current) {
+			if pair.Value >= out.minFreq && pair.Value > 0 && err == nil {
+				err = lw.writeRow(b, pair.Value, pair.Key)
+			}
+		}
+	}
+	if err != nil {
+		lw.close()
+		return 0, err
+	}
+	return len(buckets), lw.close()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testHistory = "testdata/svwiki-test-pages-meta-history.xml"
+
+func TestNextBucket(t *testing.T) {
+	var tests = map[string]string{
+		"2016":    "2017",
+		"2016-01": "2016-02",
+		"2016-09": "2016-10",
+		"2016-12": "2017-01",
+	}
+	for b, expect := range tests {
+		if result := nextBucket(b); result != expect {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+}
+
+func TestDecodePage(t *testing.T) {
+	var data = `<page><title>Titel</title><ns>0</ns><id>7</id><other><id>8</id></other>
+	<revision><id>1</id><timestamp>2016-03-01T00:00:00Z</timestamp><text>ett</text></revision>
+	<revision><id>2</id><text>två</text></revision>
+	<revision><id>3</id><text>tre</text></revision>
+	</page><page><title>Nästa</title></page>`
+	var decoder = xml.NewDecoder(strings.NewReader(data))
+	decoder.Token()
+	var texts []string
+	p, err := decodePage(decoder, func(p Page, rev Revision) bool {
+		if p.Title != "Titel" || p.ID != 7 {
+			t.Errorf(fsExp, "Titel 7", p)
+		}
+		texts = append(texts, rev.Text)
+		return rev.ID < 2 // the rest of the page is skipped
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "ett två"; strings.Join(texts, " ") != expect {
+		t.Errorf(fsExp, expect, strings.Join(texts, " "))
+	}
+	if p.Title != "Titel" || p.ID != 7 {
+		t.Errorf(fsExp, "Titel 7", p)
+	}
+
+	// the next page
+	decoder.Token()
+	if p, err = decodePage(decoder, func(Page, Revision) bool { return true }); err != nil || p.Title != "Nästa" {
+		t.Errorf(fsExp, "Nästa", p.Title)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		revisions   string
+		timeBuckets string
+		nRevisions  int
+		words       map[string]int
+		nBuckets    int
+		series      map[string]map[string]int // expected counts of some words per bucket (0 = not listed)
+	}{
+		{revisionsAll, "", 6, map[string]int{"tweet": 6, "kort": 3, "twitter": 5, "myspace": 2}, 0, nil},
+		{revisionsLatest, "", 3, map[string]int{"tweet": 2, "kort": 2, "twitter": 3, "myspace": 2}, 0, nil},
+		{revisionsAll, bucketYear, 6, map[string]int{"tweet": 6, "kort": 3, "twitter": 5}, 3, map[string]map[string]int{
+			"2016": {"tweet": 4, "kort": 1, "myspace": 2, "twitter": 0},
+			"2017": {"tweet": 2, "kort": 1, "myspace": 0, "twitter": 3},
+			"2018": {"tweet": 0, "kort": 1, "myspace": 0, "twitter": 2},
+		}},
+		{revisionsLatest, bucketYear, 3, map[string]int{"tweet": 2, "kort": 2, "twitter": 3}, 3, map[string]map[string]int{
+			"2016": {"tweet": 2, "kort": 1, "myspace": 2, "twitter": 0},
+			"2017": {"tweet": 2, "kort": 1, "myspace": 2, "twitter": 3},
+			"2018": {"tweet": 2, "kort": 2, "myspace": 2, "twitter": 3},
+		}},
+		{revisionsLatest, bucketMonth, 3, map[string]int{"tweet": 2, "kort": 2, "twitter": 3}, 24, map[string]map[string]int{
+			"2016-03": {"tweet": 2, "kort": 1, "myspace": 0, "twitter": 0},
+			"2016-04": {"tweet": 2, "kort": 1, "myspace": 0, "twitter": 0},
+			"2016-05": {"tweet": 2, "kort": 1, "myspace": 2, "twitter": 0},
+			"2017-01": {"tweet": 2, "kort": 1, "myspace": 2, "twitter": 2},
+			"2017-06": {"tweet": 2, "kort": 1, "myspace": 2, "twitter": 3},
+			"2018-02": {"tweet": 2, "kort": 2, "myspace": 2, "twitter": 3},
+		}},
+	}
+	for _, test := range tests {
+		var opts = loadOptions{pageLimit: -1, logAt: 100, namespaces: map[int]bool{0: true}, revisions: test.revisions, timeBuckets: test.timeBuckets}
+		result := loadXML(testHistory, opts)
+		if result.nPages != 3 || result.nRevisions != test.nRevisions {
+			t.Errorf(fsExp, test.nRevisions, result.nRevisions)
+		}
+		for w, expect := range test.words {
+			if result.wordFreqs[w] != expect {
+				t.Errorf("%s %s: "+fsExp, test.revisions, w, expect, result.wordFreqs[w])
+			}
+		}
+		if test.timeBuckets == "" {
+			continue
+		}
+
+		var out = outputOptions{format: formatTSV, timeFile: filepath.Join(dir, "timeseries.tsv")}
+		nBuckets, err := writeTimeSeries(result, opts, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nBuckets != test.nBuckets {
+			t.Errorf(fsExp, test.nBuckets, nBuckets)
+		}
+		data, err := ioutil.ReadFile(out.timeFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var series = make(map[string]map[string]int)
+		for _, l := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			fs := strings.Split(l, "\t")
+			if series[fs[0]] == nil {
+				series[fs[0]] = make(map[string]int)
+			}
+			key, f, _ := parseFreqLine(fs[1] + "\t" + fs[2])
+			series[fs[0]][key] = f
+		}
+		for b, words := range test.series {
+			for w, expect := range words {
+				if series[b][w] != expect {
+					t.Errorf("%s %s %s: "+fsExp, test.revisions, b, w, expect, series[b][w])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The manifest is a JSON file with the statistics and provenance of a run (input, settings and cleanup rules), so
+// that a frequency list can be reproduced and audited. By default, it's written next to the output file.
+
+// version is the version of wstats, set at build time with -ldflags "-X main.version=<version>" (otherwise
+// taken from the module version, if available)
+var version = ""
+
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	return "(devel)"
+}
+
+type manifestChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+	Verified  bool   `json:"verified"` // verified against the checksums file (-checksums)
+}
+
+type manifestStats struct {
+	LoadSeconds    float64        `json:"load_seconds"`
+	PrintSeconds   float64        `json:"print_seconds"`
+	TotalSeconds   float64        `json:"total_seconds"`
+	Pages          int            `json:"pages"`
+	NamespacePages map[string]int `json:"pages_per_namespace"`
+	Redirects      int            `json:"redirects"`
+	Revisions      int            `json:"revisions"`
+	Lines          int            `json:"lines"`
+	SkippedLines   int            `json:"skipped_lines"`
+	Words          int            `json:"words"`
+	UniqueWords    int            `json:"unique_words"`
+	Sentences      int            `json:"sentences"`
+	SentLengths    map[string]int `json:"sentence_lengths"` // no. of sentences of each length (in words)
+	Ngrams         int            `json:"ngrams,omitempty"`
+	UniqueNgrams   int            `json:"unique_ngrams,omitempty"`
+	MaxCountError  *int           `json:"max_count_error,omitempty"` // for the spacesaving counter
+}
+
+type manifest struct {
+	Tool         string            `json:"tool"`
+	Version      string            `json:"version"`
+	Created      string            `json:"created"`
+	Input        string            `json:"input"`
+	Checksum     *manifestChecksum `json:"input_checksum,omitempty"`
+	Wiki         string            `json:"wiki,omitempty"`
+	SiteName     string            `json:"site_name,omitempty"`
+	Generator    string            `json:"generator,omitempty"` // MediaWiki version of the dump
+	DumpDate     string            `json:"dump_date,omitempty"`
+	Outputs      []string          `json:"outputs"`
+	Flags        map[string]string `json:"flags"`
+	CleanupRules string            `json:"cleanup_rules_sha256"`
+	Stats        manifestStats     `json:"stats"`
+}
+
+// dumpDateRe matches the date in a dump file name, e.g. svwiki-20200101-pages-articles-multistream.xml.bz2
+var dumpDateRe = regexp.MustCompile(`-((?:19|20)[0-9]{2})([01][0-9])([0-3][0-9])-`)
+
+// dumpDate returns the dump date (yyyy-mm-dd) from the input file name, or the empty string for the latest dumps
+func dumpDate(input string) string {
+	if m := dumpDateRe.FindStringSubmatch(inputName(input)); m != nil {
+		return m[1] + "-" + m[2] + "-" + m[3]
+	}
+	return ""
+}
+
+// flagValues returns the values of all flags, including defaults
+func flagValues(f *flag.FlagSet) map[string]string {
+	var result = make(map[string]string)
+	f.VisitAll(func(fl *flag.Flag) {
+		result[fl.Name] = fl.Value.String()
+	})
+	return result
+}
+
+// rulesVersion is the version of the cleanup rules, to be increased with any change of the code of the parsers or the
+// tokenizer that changes the words counted (changes to the tables of rules and regexps are covered by the hash)
+const rulesVersion = 4
+
+// rulesHash returns a sha256 hash of the cleanup rules of the tokenizer, which change with the tokenizer options,
+// the namespaces and image options of the wiki, the tables of rules and regexps of the parser, and the rules version
+func (tk *tokenizer) rulesHash() string {
+	var h = sha256.New()
+	fmt.Fprintf(h, "version\t%d\n", rulesVersion)
+	fmt.Fprintf(h, "parser\t%s\n", tk.opts.parser)
+	if tk.opts.tokenizer == tokenizerUAX29 {
+		fmt.Fprintf(h, "tokenizer\t%s\n", tk.opts.tokenizer)
+	}
+	if tk.opts.keepCase {
+		fmt.Fprintln(h, "case\tkept")
+	}
+	if tk.opts.punkt != nil {
+		fmt.Fprintf(h, "sentences\tpunkt\t%s\n", strings.Join(tk.opts.punkt.abbreviationList(), " "))
+	}
+	if tk.profile.name != langGeneric {
+		fmt.Fprintf(h, "lang\t%s\nabbreviations\t%s\n", tk.profile.name, tk.abbreviationRe)
+	}
+	for _, rs := range [][]replacement{tk.lineReplacements, tk.tokenReplacements, tk.textReplacements} {
+		for _, r := range rs {
+			fmt.Fprintf(h, "%s\t%s\n", r.From, r.To)
+		}
+		fmt.Fprintln(h)
+	}
+	fmt.Fprintf(h, "skip\t%s\nuser\t%s\n", tk.skipRe, tk.userLinkRe)
+	if tk.opts.parser != parserLines {
+		var tags []string
+		for tag := range wikitextDropTags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		fmt.Fprintf(h, "drop\t%s\n", strings.Join(tags, " "))
+		for _, re := range []*regexp.Regexp{wikitextTagRe, wikitextURLRe, wikitextMagicWordRe, wikitextEscapedTagRe, wikitextEntityRe, wikitextHeadingRe, wikitextListRe, wikitextRuleRe, wikitextLanguageLinkRe, tk.imageOptionRe} {
+			fmt.Fprintln(h, re)
+		}
+	}
+	fmt.Fprintf(h, "sentences\t%s\n", sentenceEndRe)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func seconds(d time.Duration) float64 {
+	return float64(d.Round(time.Millisecond)) / float64(time.Second)
+}
+
+// newManifest returns the manifest of a run, with the load and print durations
+func newManifest(path string, opts loadOptions, out outputOptions, result loadResult, nUniqueWords int, nUniqueNgrams int, loadDur time.Duration, printDur time.Duration) manifest {
+	var tk = result.tk
+	if tk == nil {
+		tk = newTokenizer(defaultSiteInfo, opts.tkOpts)
+	}
+	var m = manifest{
+		Tool:         "wstats",
+		Version:      toolVersion(),
+		Created:      time.Now().UTC().Format(time.RFC3339),
+		Input:        path,
+		Wiki:         result.siteInfo.DBName,
+		SiteName:     result.siteInfo.SiteName,
+		Generator:    result.siteInfo.Generator,
+		DumpDate:     dumpDate(path),
+		Outputs:      []string{},
+		Flags:        out.flags,
+		CleanupRules: tk.rulesHash(),
+	}
+	if out.file != "" {
+		m.Outputs = append(m.Outputs, out.file)
+	}
+	if opts.ngramN > 1 && out.ngramFile != "" && out.format != formatSQLite {
+		m.Outputs = append(m.Outputs, out.ngramFile)
+	}
+	if opts.timeBuckets != "" && out.timeFile != "" && out.format != formatSQLite {
+		m.Outputs = append(m.Outputs, out.timeFile)
+	}
+	if opts.caseVariants && out.caseFile != "" && out.format != formatSQLite {
+		m.Outputs = append(m.Outputs, out.caseFile)
+	}
+	if out.corpusFile != "" {
+		m.Outputs = append(m.Outputs, out.corpusFile)
+	}
+	if c := opts.checksum; c != nil && c.digest != "" {
+		m.Checksum = &manifestChecksum{Algorithm: c.algorithm, Digest: c.digest, Verified: c.expect != ""}
+	}
+	m.Stats = manifestStats{
+		LoadSeconds:    seconds(loadDur),
+		PrintSeconds:   seconds(printDur),
+		TotalSeconds:   seconds(loadDur + printDur),
+		Pages:          result.nPages,
+		NamespacePages: make(map[string]int),
+		Redirects:      result.nRedirects,
+		Revisions:      result.nRevisions,
+		Lines:          result.nLines,
+		SkippedLines:   result.nLinesSkipped,
+		Words:          result.nWords,
+		UniqueWords:    nUniqueWords,
+		SentLengths:    make(map[string]int),
+	}
+	for l, n := range result.sentLengths {
+		m.Stats.Sentences += n
+		m.Stats.SentLengths[fmt.Sprint(l)] = n
+	}
+	for ns, n := range result.nsPages {
+		m.Stats.NamespacePages[fmt.Sprint(ns)] = n
+	}
+	if opts.ngramN > 1 {
+		m.Stats.Ngrams = result.nNgrams
+		m.Stats.UniqueNgrams = nUniqueNgrams
+	}
+	if result.approxWords != nil {
+		maxErr := result.approxWords.errorBound()
+		m.Stats.MaxCountError = &maxErr
+	}
+	return m
+}
+
+// write writes the manifest as indented JSON
+func (m manifest) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
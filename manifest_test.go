@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDumpDate(t *testing.T) {
+	var tests = map[string]string{
+		"svwiki-20200101-pages-articles-multistream.xml.bz2":                                 "2020-01-01",
+		"https://dumps.wikimedia.org/svwiki/20191220/svwiki-20191220-pages-articles.xml.bz2": "2019-12-20",
+		"dumps/svwiki-latest-pages-articles-multistream.xml.bz2":                             "",
+		testXML: "",
+	}
+	for input, expect := range tests {
+		if result := dumpDate(input); result != expect {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+}
+
+func TestRulesHash(t *testing.T) {
+	var wikitext = newTokenizer(defaultSiteInfo, tokenizerOptions{parser: parserWikitext}).rulesHash()
+	var lines = newTokenizer(defaultSiteInfo, tokenizerOptions{parser: parserLines}).rulesHash()
+	if wikitext != newTokenizer(defaultSiteInfo, tokenizerOptions{parser: parserWikitext}).rulesHash() {
+		t.Errorf("expected the same hash for the same rules")
+	}
+	if wikitext == lines {
+		t.Errorf("expected different hashes for different parsers")
+	}
+	var si = defaultSiteInfo
+	si.Aliases = []Namespace{{Key: categoryNamespace, Name: "Kat"}}
+	if newTokenizer(si, tokenizerOptions{parser: parserWikitext}).rulesHash() == wikitext {
+		t.Errorf("expected different hashes for different namespaces")
+	}
+	si = defaultSiteInfo
+	si.ImageOptions = []string{"bild"}
+	if newTokenizer(si, tokenizerOptions{parser: parserWikitext}).rulesHash() == wikitext {
+		t.Errorf("expected different hashes for different image options")
+	}
+	defer delete(wikitextDropTags, "poem")
+	wikitextDropTags["poem"] = true
+	if newTokenizer(defaultSiteInfo, tokenizerOptions{parser: parserWikitext}).rulesHash() == wikitext {
+		t.Errorf("expected different hashes for different wikitext rules")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	xml, err := ioutil.ReadFile(testXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts = loadOptions{pageLimit: -1, logAt: 100, namespaces: map[int]bool{0: true}}
+	opts.checksum = &checksum{algorithm: "sha1", hash: sha1.New()}
+	result := loadXML(testXML, opts)
+	var out = outputOptions{file: filepath.Join(dir, "words.txt"), flags: map[string]string{"mf": "2"}}
+	out.manifest = out.file + ".manifest.json"
+	m := newManifest(testXML, opts, out, result, len(result.wordFreqs), 0, 1500*time.Millisecond, 250*time.Millisecond)
+	if err := m.write(out.manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(out.manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read manifest
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name   string
+		expect interface{}
+		result interface{}
+	}{
+		{"input", testXML, read.Input},
+		{"wiki", result.siteInfo.DBName, read.Wiki},
+		{"checksum", fmt.Sprintf("sha1 %x false", sha1.Sum(xml)), fmt.Sprintf("%s %s %v", read.Checksum.Algorithm, read.Checksum.Digest, read.Checksum.Verified)},
+		{"flags", "2", read.Flags["mf"]},
+		{"outputs", fmt.Sprint([]string{out.file}), fmt.Sprint(read.Outputs)},
+		{"rules", result.tk.rulesHash(), read.CleanupRules},
+		{"pages", result.nPages, read.Stats.Pages},
+		{"words", result.nWords, read.Stats.Words},
+		{"unique words", len(result.wordFreqs), read.Stats.UniqueWords},
+		{"total seconds", 1.75, read.Stats.TotalSeconds},
+	}
+	for _, test := range tests {
+		if test.expect != test.result {
+			t.Errorf("%s: "+fsExp, test.name, test.expect, test.result)
+		}
+	}
+	if read.Wiki == "" || read.Stats.Words == 0 {
+		t.Errorf("expected wiki and word count in manifest: %s", data)
+	}
+}
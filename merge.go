@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The merge subcommand merges frequency lists (tsv output of wstats, e.g. for several wikis, or for the parts of a
+// dump) into a single list sorted by frequency, summing the counts of each word. The counts are kept within a memory
+// budget, and written to temporary files when the budget is exceeded (see spill.go), so that lists larger than
+// memory can be merged.
+
+const spillMerge = "merge"
+
+// parseFreqLine parses a line of a tsv frequency list (count<tab>word, any other columns are ignored)
+func parseFreqLine(line string) (string, int, bool) {
+	fs := strings.SplitN(line, "\t", 3)
+	f, err := strconv.Atoi(fs[0])
+	if err != nil || len(fs) < 2 {
+		return "", 0, false
+	}
+	return fs[1], f, true
+}
+
+// readFreqs reads a tsv frequency list, and calls add for each count
+func readFreqs(path string, add func(key string, f int)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := scanFreqs(file, add); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// scanFreqs reads a tsv frequency list from r, and calls add for each count (lines of up to 16 MB)
+func scanFreqs(r io.Reader, add func(key string, f int)) error {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var n = 0
+	for scanner.Scan() {
+		n++
+		var line = scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, f, ok := parseFreqLine(line)
+		if !ok {
+			return fmt.Errorf("invalid frequency list, line %d: %s", n, line)
+		}
+		add(key, f)
+	}
+	return scanner.Err()
+}
+
+// mergeFreqLists sums the counts of the lists, and writes the merged list (limited by min freq) to the writer
+// created by newWriter, given the total count. It returns the number of unique words, and the total count.
+func mergeFreqLists(paths []string, sp *spiller, minFreq int, newWriter func(total int) (listWriter, error)) (int, int, error) {
+	var m = make(map[string]int)
+	var total = 0
+	var spillErr error
+	for _, path := range paths {
+		err := readFreqs(path, func(key string, f int) {
+			total += f
+			sp.add(m, key, f)
+			if sp.size > sp.budget && spillErr == nil {
+				spillErr = sp.spill(spillMerge, m)
+				m = make(map[string]int)
+				sp.size = 0
+			}
+		})
+		if err == nil {
+			err = spillErr
+		}
+		if err != nil {
+			removeFiles(sp.runs[spillMerge])
+			return 0, 0, err
+		}
+	}
+	lw, err := newWriter(total)
+	if err != nil {
+		removeFiles(sp.runs[spillMerge])
+		return 0, 0, err
+	}
+	n, err := sp.writeFreqList(lw, spillMerge, m, minFreq)
+	if err != nil {
+		lw.close()
+		return 0, 0, err
+	}
+	return n, total, lw.close()
+}
+
+func mergeCmd(args []string) {
+	var usage = `
+wstats merge merges frequency lists (tsv output of wstats) into a single list sorted by frequency, summing the counts
+of each word. Lists larger than memory are merged using temporary files.
+
+Usage:
+ $ go run . merge <flags> <word frequency lists>
+
+Cmd line flags:
+  -mf int          min freq: lower limit for word frequencies to be printed (optional, default = 0)
+  -format string   output format: tsv, jsonl, csv (with header), sqlite or parquet (optional, default = tsv)
+  -out string      output file (optional, default = standard out, required for sqlite)
+  -maxmem int      memory budget in MB for the counts, written to temporary files when exceeded (optional, default = 1000)
+  -tmpdir string   directory for temporary files (optional, default = system temp dir)
+  -h(elp)          help: print help message
+
+Example usage:
+  $ go run . merge -mf 2 -out nordic.freq svwiki.freq nowiki.freq dawiki.freq fiwiki.freq iswiki.freq
+
+`
+	var f = flag.NewFlagSet("merge", flag.ExitOnError)
+	var minFreq = f.Int("mf", 0, "min freq")
+	var format = f.String("format", formatTSV, "output format")
+	var outFile = f.String("out", "", "output file")
+	var maxMem = f.Int("maxmem", 1000, "memory budget in MB")
+	var tmpDir = f.String("tmpdir", os.TempDir(), "directory for temporary files")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage)
+	}
+	if err := f.Parse(args); err != nil || len(f.Args()) < 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if !contains(outputFormats, *format) {
+		log.Fatal("Invalid output format: ", *format)
+	}
+	if *format == formatSQLite && *outFile == "" {
+		log.Fatal("-format sqlite requires an output file (-out)")
+	}
+	if *maxMem < 1 {
+		log.Fatal("Invalid memory budget: ", *maxMem)
+	}
+
+	var sp = newSpiller(*tmpDir, *maxMem*1024*1024)
+	n, total, err := mergeFreqLists(f.Args(), sp, *minFreq, func(total int) (listWriter, error) {
+		if *format == formatParquet {
+			return newParquetWriter(*outFile, "word", total, nil)
+		}
+		return newListWriter(*format, *outFile, "words", []column{{"count", "INTEGER"}, {"word", "TEXT"}})
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print("No. of lists         : ", lIntPrettyPrint(len(f.Args())))
+	log.Print("No. of words         : ", lIntPrettyPrint(total))
+	log.Print("No. of unique words  : ", lIntPrettyPrint(n))
+}
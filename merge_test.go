@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var lists = []string{
+		"5\toch\n3\ti\n1\tatt\n",
+		"4\ti\n2\tatt\t0.5\n1\tär\n",
+		"\n2\tsom\n1\toch\n",
+	}
+	var paths []string
+	for i, l := range lists {
+		path := filepath.Join(dir, "list"+string(rune('a'+i))+".freq")
+		if err := ioutil.WriteFile(path, []byte(l), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	var tests = []struct {
+		budget  int
+		minFreq int
+		expect  string
+	}{
+		{1000000, 0, "7\ti\n6\toch\n3\tatt\n2\tsom\n1\tär\n"},
+		{1000000, 3, "7\ti\n6\toch\n3\tatt\n"},
+		// spill after every word
+		{1, 0, "7\ti\n6\toch\n3\tatt\n2\tsom\n1\tär\n"},
+		{1, 2, "7\ti\n6\toch\n3\tatt\n2\tsom\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		var sp = newSpiller(dir, test.budget)
+		n, total, err := mergeFreqLists(paths, sp, test.minFreq, func(total int) (listWriter, error) {
+			return &tsvWriter{bufio.NewWriter(&buf), nopCloser{&buf}}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expect || n != 5 || total != 19 {
+			t.Errorf(fsExp, test.expect, buf.String())
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.run"))
+	if len(files) > 0 {
+		t.Errorf("temporary files not removed: %v", files)
+	}
+
+	if _, _, err := mergeFreqLists([]string{filepath.Join(dir, "nonexistent")}, newSpiller(dir, 1), 0, nil); err == nil {
+		t.Errorf("expected error for nonexistent file")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Multistream dumps (*-pages-articles-multistream.xml.bz2) are concatenated bz2 streams of (normally) 100 pages each.
+// The companion index file (*-multistream-index.txt.bz2) has one line per page: <stream offset>:<page id>:<title>
+
+type indexEntry struct {
+	Offset int64
+	ID     int
+	Title  string
+}
+
+func parseIndexLine(l string) (indexEntry, error) {
+	fs := strings.SplitN(l, ":", 3)
+	if len(fs) != 3 {
+		return indexEntry{}, fmt.Errorf("invalid index line: %s", l)
+	}
+	offset, err := strconv.ParseInt(fs[0], 10, 64)
+	if err != nil {
+		return indexEntry{}, fmt.Errorf("invalid offset in index line: %s", l)
+	}
+	id, err := strconv.Atoi(fs[1])
+	if err != nil {
+		return indexEntry{}, fmt.Errorf("invalid page id in index line: %s", l)
+	}
+	return indexEntry{Offset: offset, ID: id, Title: fs[2]}, nil
+}
+
+func readIndex(path string) ([]indexEntry, error) {
+	input, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	var result []indexEntry
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if len(strings.TrimSpace(l)) == 0 {
+			continue
+		}
+		e, err := parseIndexLine(l)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, scanner.Err()
+}
+
+// start: page selection
+
+// pageSelection holds the pages requested by the user, by title, page id or page id range
+type pageSelection struct {
+	titles map[string]bool
+	ids    map[int]bool
+	idFrom int
+	idTo   int // inclusive, unset if 0
+}
+
+func (s pageSelection) isEmpty() bool {
+	return len(s.titles) == 0 && len(s.ids) == 0 && s.idTo == 0
+}
+
+func (s pageSelection) accept(id int, title string) bool {
+	return s.titles[title] || s.ids[id] || (s.idTo > 0 && id >= s.idFrom && id <= s.idTo)
+}
+
+// readTitles reads a file with one page title per line
+func readTitles(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var result = make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		l := strings.TrimSpace(scanner.Text())
+		if len(l) > 0 {
+			result[l] = true
+		}
+	}
+	return result, scanner.Err()
+}
+
+// parseIDs parses a comma separated list of page ids
+func parseIDs(s string) (map[int]bool, error) {
+	var result = make(map[int]bool)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page id: %s", v)
+		}
+		result[id] = true
+	}
+	return result, nil
+}
+
+// parseIDRange parses a page id range on the form <from>-<to>
+func parseIDRange(s string) (int, int, error) {
+	fs := strings.SplitN(s, "-", 2)
+	if len(fs) != 2 {
+		return 0, 0, fmt.Errorf("invalid page id range: %s", s)
+	}
+	from, err1 := strconv.Atoi(strings.TrimSpace(fs[0]))
+	to, err2 := strconv.Atoi(strings.TrimSpace(fs[1]))
+	if err1 != nil || err2 != nil || to < from || to <= 0 {
+		return 0, 0, fmt.Errorf("invalid page id range: %s", s)
+	}
+	return from, to, nil
+}
+
+// end: page selection
+
+// streamSpan is a byte range of the multistream file containing one bz2 stream
+type streamSpan struct {
+	offset int64
+	length int64 // -1 = until end of file
+}
+
+// selectStreams returns the streams containing at least one of the selected pages, in file order
+func selectStreams(entries []indexEntry, sel pageSelection) []streamSpan {
+	var offsets []int64
+	var seen = make(map[int64]bool)
+	var selected = make(map[int64]bool)
+	for _, e := range entries {
+		if !seen[e.Offset] {
+			seen[e.Offset] = true
+			offsets = append(offsets, e.Offset)
+		}
+		if sel.accept(e.ID, e.Title) {
+			selected[e.Offset] = true
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	var result []streamSpan
+	for i, o := range offsets {
+		if !selected[o] {
+			continue
+		}
+		var length int64 = -1
+		if i+1 < len(offsets) {
+			length = offsets[i+1] - o
+		}
+		result = append(result, streamSpan{o, length})
+	}
+	return result
+}
+
+// openStream opens a single bz2 stream of a local or remote (using http range requests) multistream file
+func openStream(path string, span streamSpan) (io.ReadCloser, error) {
+	path = download.cached(path)
+	if strings.HasPrefix(path, "http") {
+		body, err := openURL(path, span.offset, span.length, download)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{bzip2.NewReader(body), body}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var section io.Reader
+	if span.length < 0 {
+		if _, err := file.Seek(span.offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+		section = file
+	} else {
+		section = io.NewSectionReader(file, span.offset, span.length)
+	}
+	return readCloser{bzip2.NewReader(section), file}, nil
+}
+
+// loadMultistream reads the selected pages only, by seeking to the streams listed for them in the index file
+func loadMultistream(path string, opts loadOptions) loadResult {
+	if !strings.HasSuffix(path, "bz2") {
+		log.Fatal("Random access using an index file requires a multistream bz2 dump: ", path)
+	}
+	// random access is fast, so no checkpoints are needed
+	opts.checkpoint = nil
+	entries, err := readIndex(opts.index)
+	if err != nil {
+		log.Fatal(err)
+	}
+	spans := selectStreams(entries, opts.selection)
+	log.Print("Streams to read : ", len(spans))
+
+	var result = newLoadResult(opts)
+	// the first stream (before the first page) holds the siteinfo
+	if len(entries) > 0 && entries[0].Offset > 0 {
+		spans = append([]streamSpan{{0, entries[0].Offset}}, spans...)
+	}
+	for _, span := range spans {
+		input, err := openStream(path, span)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ok := readPages(xml.NewDecoder(input), &result, opts, nil)
+		input.Close()
+		if !ok {
+			break
+		}
+	}
+	return result
+}
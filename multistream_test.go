@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testDump = "testdata/svwiki-test-pages-articles-multistream.xml.bz2"
+var testIndex = "testdata/svwiki-test-pages-articles-multistream-index.txt.bz2"
+var testXML = "testdata/svwiki-test-pages-articles.xml"
+
+func TestParseIndexLine(t *testing.T) {
+	tests := map[string]indexEntry{
+		"544:1:Ateism":                 {544, 1, "Ateism"},
+		"1206:5:Mall:Infobox":          {1206, 5, "Mall:Infobox"},
+		"616:123:Titel: med kolon: 2:": {616, 123, "Titel: med kolon: 2:"},
+	}
+	for input, expect := range tests {
+		result, err := parseIndexLine(input)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		if result != expect {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+	for _, input := range []string{"544:1", "x:1:Ateism", "544:x:Ateism"} {
+		if _, err := parseIndexLine(input); err == nil {
+			t.Errorf("expected error for index line '%s'", input)
+		}
+	}
+}
+
+func TestSelectStreams(t *testing.T) {
+	entries, err := readIndex(testIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 8 {
+		t.Errorf(fsExp, 8, len(entries))
+	}
+
+	tests := []struct {
+		sel    pageSelection
+		expect []streamSpan
+	}{
+		{pageSelection{titles: map[string]bool{"Jakarta": true}}, []streamSpan{{1206, 551}}},
+		{pageSelection{ids: map[int]bool{1: true, 8: true}}, []streamSpan{{544, 662}, {1757, -1}}},
+		{pageSelection{idFrom: 3, idTo: 4}, []streamSpan{{544, 662}, {1206, 551}}},
+		{pageSelection{titles: map[string]bool{"Finns inte": true}}, nil},
+	}
+	for _, test := range tests {
+		result := selectStreams(entries, test.sel)
+		if !reflect.DeepEqual(result, test.expect) {
+			t.Errorf(fsExp, test.expect, result)
+		}
+	}
+}
+
+func TestLoadMultistream(t *testing.T) {
+	var opts = loadOptions{pageLimit: -1, logAt: 100, index: testIndex}
+	opts.selection.titles = map[string]bool{"Jakarta": true, "Karlskrona": true}
+
+	result := loadXML(testDump, opts)
+	if result.nPages != 2 {
+		t.Errorf(fsExp, 2, result.nPages)
+	}
+	if result.wordFreqs["jakarta"] != 2 || result.wordFreqs["karlskrona"] != 2 {
+		t.Errorf("unexpected word freqs: %v", result.wordFreqs)
+	}
+
+	// a full scan of the uncompressed dump should give the same result
+	opts.index = ""
+	expect := loadXML(testXML, opts)
+	if !sameCounts(result, expect) {
+		t.Errorf(fsExp, expect, result)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Word n-gram counting. N-grams are counted within sentences, so that an n-gram never spans a line
+// (paragraph, heading, list item) or sentence boundary.
+
+// sentenceEndRe matches sentence final punctuation, followed by optional closing quotes/brackets and white space
+var sentenceEndRe = regexp.MustCompile(`[.!?…]+["”»’')\]]*\s+`)
+
+// splitSentences splits a line of plain text into sentences. A sentence boundary is a sentence final punctuation mark
+// followed by white space and a word starting with an upper case letter, a digit or an opening quote. The period of
+// an abbreviation (lower case, see profiles.go) is not a sentence boundary.
+func splitSentences(line string, abbreviations map[string]bool) []string {
+	var result []string
+	var start = 0
+	for _, m := range sentenceEndRe.FindAllStringIndex(line, -1) {
+		var prev = strings.TrimRightFunc(line[:m[1]], unicode.IsSpace)
+		if abbreviations[strings.ToLower(prev[strings.LastIndexFunc(prev, unicode.IsSpace)+1:])] {
+			continue
+		}
+		next, _ := utf8.DecodeRuneInString(line[m[1]:])
+		if unicode.IsUpper(next) || unicode.IsDigit(next) || strings.ContainsRune("\"“”«»'(", next) {
+			result = append(result, line[start:m[1]])
+			start = m[1]
+		}
+	}
+	if start < len(line) {
+		result = append(result, line[start:])
+	}
+	return result
+}
+
+// ngrams returns the n-grams of the words, with the words separated by space
+func ngrams(words []string, n int) []string {
+	var result []string
+	for i := 0; i+n <= len(words); i++ {
+		result = append(result, strings.Join(words[i:i+n], " "))
+	}
+	return result
+}
+
+// countNgrams counts the n-grams of each sentence
+func countNgrams(sentences [][]string, n int) map[string]int {
+	var result = make(map[string]int)
+	for _, words := range sentences {
+		for _, ngram := range ngrams(words, n) {
+			result[ngram]++
+		}
+	}
+	return result
+}
+
+// sentenceStats returns the no. of sentences, and the mean, median, 90th percentile and max of the sentence
+// lengths (in words), given the no. of sentences of each length
+func sentenceStats(lengths map[int]int) (n int, mean float64, median int, p90 int, max int) {
+	var keys = sortedKeys(lengths)
+	var sum = 0
+	for _, l := range keys {
+		n += lengths[l]
+		sum += l * lengths[l]
+	}
+	if n == 0 {
+		return 0, 0, 0, 0, 0
+	}
+	var acc = 0
+	for _, l := range keys {
+		acc += lengths[l]
+		if median == 0 && 2*acc >= n {
+			median = l
+		}
+		if p90 == 0 && 10*acc >= 9*n {
+			p90 = l
+		}
+	}
+	return n, float64(sum) / float64(n), median, p90, keys[len(keys)-1]
+}
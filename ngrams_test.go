@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	var tests = map[string][]string{
+		"Ateism är avsaknad av tro. Ordet användes i Europa.": {"Ateism är avsaknad av tro. ", "Ordet användes i Europa."},
+		"Jakarta är stor! 1900 fanns det? Ja.":                {"Jakarta är stor! ", "1900 fanns det? ", "Ja."},
+		"Han sa: \"Nej.\" Sedan gick han.":                    {"Han sa: \"Nej.\" ", "Sedan gick han."},
+		"Det finns bl.a. städer och ca. tre öar.":             {"Det finns bl.a. städer och ca. tre öar."},
+		"Utan punkt": {"Utan punkt"},
+		"(Se även Amager.) «Stranden» är populär.": {"(Se även Amager.) ", "«Stranden» är populär."},
+	}
+	for input, expect := range tests {
+		result := splitSentences(input, nil)
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+}
+
+func TestNgrams(t *testing.T) {
+	var words = []string{"staden", "är", "känd", "för"}
+	var tests = map[int][]string{
+		2: {"staden är", "är känd", "känd för"},
+		3: {"staden är känd", "är känd för"},
+		4: {"staden är känd för"},
+		5: nil,
+	}
+	for n, expect := range tests {
+		result := ngrams(words, n)
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+}
+
+func TestCountNgrams(t *testing.T) {
+	var text = "Karlskrona är en stad i [[Blekinge]]. Staden är känd.\nStaden är känd för [[svenska marinen]]."
+	_, _, sentences := defaultTokenizer.tokenizeSentences(text)
+	var result = countNgrams(sentences, 2)
+	var expect = map[string]int{
+		"karlskrona är": 1, "är en": 1, "en stad": 1, "stad i": 1, "i blekinge": 1,
+		"staden är": 2, "är känd": 2, "känd för": 1, "för svenska": 1, "svenska marinen": 1,
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(fsExp, expect, result)
+	}
+
+	// same result for parallel processing
+	var opts = loadOptions{pageLimit: -1, logAt: 100, ngramN: 3, workers: 1}
+	seq := loadXML(testDump, opts)
+	opts.workers = 3
+	par := loadXML(testDump, opts)
+	if len(seq.ngramFreqs) == 0 || !reflect.DeepEqual(seq.ngramFreqs, par.ngramFreqs) {
+		t.Errorf(fsExp, seq.ngramFreqs, par.ngramFreqs)
+	}
+	if seq.ngramFreqs["staden är känd"] != 1 {
+		t.Errorf(fsExp, 1, seq.ngramFreqs["staden är känd"])
+	}
+}
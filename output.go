@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	_ "modernc.org/sqlite" // sqlite driver
+)
+
+// Output writers for the frequency lists: tsv (count<tab>word, without header), json lines, csv (with header),
+// or a sqlite database, with a table for the words, a table for the n-grams, and a table for the statistics of the run.
+
+const (
+	formatTSV    = "tsv"
+	formatJSONL  = "jsonl"
+	formatCSV    = "csv"
+	formatSQLite = "sqlite"
+)
+
+var outputFormats = []string{formatTSV, formatJSONL, formatCSV, formatSQLite, formatParquet}
+
+type column struct {
+	name    string
+	sqlType string
+}
+
+// listWriter writes the rows of a frequency list, with the values in the order of the columns
+type listWriter interface {
+	writeRow(values ...interface{}) error
+	close() error
+}
+
+// newListWriter creates a writer for the format. The path is a file name (stdout if empty), and table is
+// the table name used for sqlite.
+func newListWriter(format string, path string, table string, columns []column) (listWriter, error) {
+	if format == formatSQLite {
+		return newSQLiteWriter(path, table, columns)
+	}
+	var w io.WriteCloser = nopCloser{os.Stdout}
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w = file
+	}
+	var buf = bufio.NewWriter(w)
+	switch format {
+	case formatTSV:
+		return &tsvWriter{buf, w}, nil
+	case formatJSONL:
+		return &jsonlWriter{buf, w, columns}, nil
+	case formatCSV:
+		var cw = &csvWriter{csv.NewWriter(buf), buf, w}
+		var header []string
+		for _, c := range columns {
+			header = append(header, c.name)
+		}
+		return cw, cw.w.Write(header)
+	}
+	return nil, fmt.Errorf("invalid output format: %s", format)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// formatValue formats a value for the text formats
+func formatValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return fmt.Sprintf("%.4f", f)
+	}
+	return fmt.Sprint(v)
+}
+
+type tsvWriter struct {
+	buf *bufio.Writer
+	w   io.Closer
+}
+
+func (tw *tsvWriter) writeRow(values ...interface{}) error {
+	var fs = make([]string, len(values))
+	for i, v := range values {
+		fs[i] = formatValue(v)
+	}
+	_, err := fmt.Fprintln(tw.buf, strings.Join(fs, "\t"))
+	return err
+}
+
+func (tw *tsvWriter) close() error {
+	if err := tw.buf.Flush(); err != nil {
+		tw.w.Close()
+		return err
+	}
+	return tw.w.Close()
+}
+
+type jsonlWriter struct {
+	buf     *bufio.Writer
+	w       io.Closer
+	columns []column
+}
+
+func (jw *jsonlWriter) writeRow(values ...interface{}) error {
+	// the object is built by hand, to keep the column order
+	var fs = make([]string, len(values))
+	for i, v := range values {
+		if f, ok := v.(float64); ok {
+			v = math.Round(f*10000) / 10000
+		}
+		key, _ := json.Marshal(jw.columns[i].name)
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fs[i] = string(key) + ":" + string(value)
+	}
+	_, err := fmt.Fprintln(jw.buf, "{"+strings.Join(fs, ",")+"}")
+	return err
+}
+
+func (jw *jsonlWriter) close() error {
+	if err := jw.buf.Flush(); err != nil {
+		jw.w.Close()
+		return err
+	}
+	return jw.w.Close()
+}
+
+type csvWriter struct {
+	w   *csv.Writer
+	buf *bufio.Writer
+	out io.Closer
+}
+
+func (cw *csvWriter) writeRow(values ...interface{}) error {
+	var fs = make([]string, len(values))
+	for i, v := range values {
+		fs[i] = formatValue(v)
+	}
+	return cw.w.Write(fs)
+}
+
+func (cw *csvWriter) close() error {
+	cw.w.Flush()
+	err := cw.w.Error()
+	if err == nil {
+		err = cw.buf.Flush()
+	}
+	if err != nil {
+		cw.out.Close()
+		return err
+	}
+	return cw.out.Close()
+}
+
+// start: sqlite
+
+type sqliteWriter struct {
+	db   *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+}
+
+// newSQLiteWriter (re)creates the table in the database, and prepares the inserts (in a single transaction)
+func newSQLiteWriter(path string, table string, columns []column) (*sqliteWriter, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the sqlite output format requires an output file")
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	var defs, params []string
+	for _, c := range columns {
+		defs = append(defs, c.name+" "+c.sqlType)
+		params = append(params, "?")
+	}
+	var sw = &sqliteWriter{db: db}
+	for _, q := range []string{
+		"DROP TABLE IF EXISTS " + table,
+		"CREATE TABLE " + table + " (" + strings.Join(defs, ", ") + ")",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if sw.tx, err = db.Begin(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if sw.stmt, err = sw.tx.Prepare("INSERT INTO " + table + " VALUES (" + strings.Join(params, ", ") + ")"); err != nil {
+		sw.tx.Rollback()
+		db.Close()
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *sqliteWriter) writeRow(values ...interface{}) error {
+	_, err := sw.stmt.Exec(values...)
+	return err
+}
+
+func (sw *sqliteWriter) close() error {
+	sw.stmt.Close()
+	if err := sw.tx.Commit(); err != nil {
+		sw.db.Close()
+		return err
+	}
+	return sw.db.Close()
+}
+
+// writeSQLiteMetadata writes the statistics and settings of the run to the metadata table of the database
+func writeSQLiteMetadata(path string, info []keyValue) error {
+	w, err := newSQLiteWriter(path, "metadata", []column{{"name", "TEXT"}, {"value", "TEXT"}})
+	if err != nil {
+		return err
+	}
+	for _, kv := range info {
+		if err := w.writeRow(kv.key, kv.value); err != nil {
+			w.close()
+			return err
+		}
+	}
+	return w.close()
+}
+
+// writeSentenceLengths writes the no. of sentences of each length to the sentencelengths table of the database
+func writeSentenceLengths(path string, lengths map[int]int) error {
+	w, err := newSQLiteWriter(path, "sentencelengths", []column{{"length", "INTEGER"}, {"count", "INTEGER"}})
+	if err != nil {
+		return err
+	}
+	for _, l := range sortedKeys(lengths) {
+		if err := w.writeRow(l, lengths[l]); err != nil {
+			w.close()
+			return err
+		}
+	}
+	return w.close()
+}
+
+// end: sqlite
+
+type keyValue struct {
+	key   string
+	value string
+}
+
+// runInfo returns the statistics and settings of the run, as printed to standard error
+func runInfo(path string, opts loadOptions, result loadResult, nUniqueWords int, nUniqueNgrams int) []keyValue {
+	var info = []keyValue{
+		{"path", path},
+		{"wiki", result.siteInfo.DBName},
+		{"parser", opts.tkOpts.parser},
+		{"tokenizer", opts.tkOpts.tokenizer},
+		{"keep_case", fmt.Sprint(opts.tkOpts.keepCase)},
+	}
+	if result.tk != nil {
+		info = append(info, keyValue{"lang", result.tk.profile.name})
+	}
+	info = append(info, keyValue{"counter", opts.counter}, keyValue{"pages", fmt.Sprint(result.nPages)})
+	for _, ns := range sortedKeys(result.nsPages) {
+		info = append(info, keyValue{fmt.Sprintf("pages_ns_%d", ns), fmt.Sprint(result.nsPages[ns])})
+	}
+	if opts.namespaces != nil {
+		info = append(info, keyValue{"namespaces", strings.Trim(fmt.Sprint(sortedKeys(opts.namespaces)), "[]")})
+	} else {
+		info = append(info, keyValue{"namespaces", "all"})
+	}
+	info = append(info,
+		keyValue{"redirects", fmt.Sprint(result.nRedirects)},
+		keyValue{"revisions", fmt.Sprint(result.nRevisions)},
+		keyValue{"lines", fmt.Sprint(result.nLines)},
+		keyValue{"skipped_lines", fmt.Sprint(result.nLinesSkipped)},
+		keyValue{"words", fmt.Sprint(result.nWords)},
+		keyValue{"unique_words", fmt.Sprint(nUniqueWords)},
+	)
+	nSentences, meanLength, medianLength, p90Length, maxLength := sentenceStats(result.sentLengths)
+	info = append(info,
+		keyValue{"sentences", fmt.Sprint(nSentences)},
+		keyValue{"sentence_length_mean", fmt.Sprintf("%.2f", meanLength)},
+		keyValue{"sentence_length_median", fmt.Sprint(medianLength)},
+		keyValue{"sentence_length_p90", fmt.Sprint(p90Length)},
+		keyValue{"sentence_length_max", fmt.Sprint(maxLength)},
+	)
+	if opts.tkOpts.punkt != nil {
+		info = append(info, keyValue{"sentence_splitter", sentencesPunkt}, keyValue{"punkt_abbreviations", strings.Join(opts.tkOpts.punkt.abbreviationList(), " ")})
+	}
+	if opts.ngramN > 1 {
+		info = append(info, keyValue{"ngram_size", fmt.Sprint(opts.ngramN)}, keyValue{"unique_ngrams", fmt.Sprint(nUniqueNgrams)})
+	}
+	if result.approxWords != nil {
+		info = append(info, keyValue{"capacity", fmt.Sprint(opts.capacity)}, keyValue{"max_count_error", fmt.Sprint(result.approxWords.errorBound())})
+	}
+	return info
+}
+
+// listColumns returns the columns of the frequency list, for words or n-grams
+func listColumns(result loadResult, opts loadOptions, key string) []column {
+	var columns = []column{{"count", "INTEGER"}, {key, "TEXT"}}
+	switch {
+	case result.approxWords != nil:
+		columns = append(columns, column{"error", "INTEGER"})
+	case key == "word" && opts.dispersionParts > 0:
+		columns = append(columns, column{"docfreq", "INTEGER"}, column{"juilland_d", "REAL"}, column{"gries_dp", "REAL"})
+	}
+	return columns
+}
+
+// writeWordList writes the word frequency list (limited by min freq). It returns the number of unique words.
+func writeWordList(result loadResult, opts loadOptions, out outputOptions) (int, error) {
+	var lw listWriter
+	var err error
+	if out.format == formatParquet {
+		lw, err = newParquetWriter(out.file, "word", result.nWords, result.docFreqs)
+	} else {
+		lw, err = newListWriter(out.format, out.file, "words", listColumns(result, opts, "word"))
+	}
+	if err != nil {
+		return 0, err
+	}
+	var n = len(result.wordFreqs)
+	switch {
+	case result.approxWords != nil:
+		n, err = result.approxWords.writeFreqList(lw, out.minFreq)
+	case opts.dispersionParts > 0:
+		for _, pair := range sortByWordCount(result.wordFreqs) {
+			if pair.Value >= out.minFreq && err == nil {
+				pf := result.partFreqs[pair.Key]
+				err = lw.writeRow(pair.Value, pair.Key, result.docFreqs[pair.Key], juillandsD(pf, result.partSizes), griesDP(pf, result.partSizes))
+			}
+		}
+	default:
+		n, err = result.spill.writeFreqList(lw, spillWords, result.wordFreqs, out.minFreq)
+	}
+	if err != nil {
+		lw.close()
+		return 0, err
+	}
+	return n, lw.close()
+}
+
+// writeNgramList writes the n-gram frequency list (limited by the n-gram min freq), to the n-gram file, or to the
+// ngrams table for sqlite. It returns the number of unique n-grams.
+func writeNgramList(result loadResult, opts loadOptions, out outputOptions) (int, error) {
+	var path = out.ngramFile
+	if out.format == formatSQLite {
+		path = out.file
+	}
+	var lw listWriter
+	var err error
+	if out.format == formatParquet {
+		lw, err = newParquetWriter(path, "ngram", result.nNgrams, result.ngramDocFreqs)
+	} else {
+		lw, err = newListWriter(out.format, path, "ngrams", listColumns(result, opts, "ngram"))
+	}
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	if result.approxNgrams != nil {
+		n, err = result.approxNgrams.writeFreqList(lw, out.ngramMinFreq)
+	} else {
+		n, err = result.spill.writeFreqList(lw, spillNgrams, result.ngramFreqs, out.ngramMinFreq)
+	}
+	if err != nil {
+		lw.close()
+		return 0, err
+	}
+	return n, lw.close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var opts = loadOptions{pageLimit: -1, logAt: 100, ngramN: 2, namespaces: map[int]bool{0: true}}
+	result := loadXML(testXML, opts)
+
+	var tests = map[string]string{
+		formatTSV:   "6\tär\n4\ti\n",
+		formatCSV:   "count,word\n6,är\n4,i\n",
+		formatJSONL: "{\"count\":6,\"word\":\"är\"}\n{\"count\":4,\"word\":\"i\"}\n",
+	}
+	for format, expect := range tests {
+		var out = outputOptions{minFreq: 4, format: format, file: filepath.Join(dir, "words."+format)}
+		if _, err := writeWordList(result, opts, out); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(out.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expect {
+			t.Errorf(fsExp, expect, string(data))
+		}
+		if format == formatJSONL {
+			var row struct {
+				Count int
+				Word  string
+			}
+			if err := json.Unmarshal([]byte(strings.Split(string(data), "\n")[0]), &row); err != nil || row.Count != 6 {
+				t.Errorf("unexpected json: %s (%v)", data, err)
+			}
+		}
+	}
+
+	// sqlite: words, n-grams and metadata tables
+	var out = outputOptions{minFreq: 2, ngramMinFreq: 1, format: formatSQLite, file: filepath.Join(dir, "wstats.db")}
+	nWords, err := writeWordList(result, opts, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nNgrams, err := writeNgramList(result, opts, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSQLiteMetadata(out.file, runInfo(testXML, opts, result, nWords, nNgrams)); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", out.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var queries = map[string]string{
+		"SELECT count FROM words WHERE word = 'är'":               "6",
+		"SELECT COUNT(*) FROM words":                              "10",
+		"SELECT count FROM ngrams WHERE ngram = 'staden är'":      "2",
+		"SELECT value FROM metadata WHERE name = 'pages'":         "5",
+		"SELECT value FROM metadata WHERE name = 'wiki'":          "svwiki",
+		"SELECT value FROM metadata WHERE name = 'pages_ns_14'":   "1",
+		"SELECT value FROM metadata WHERE name = 'unique_ngrams'": "48",
+	}
+	for q, expect := range queries {
+		var result string
+		if err := db.QueryRow(q).Scan(&result); err != nil {
+			t.Errorf("%s: %v", q, err)
+		} else if result != expect {
+			t.Errorf("%s: "+fsExp, q, expect, result)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// Parallel processing of multistream bz2 dumps: the raw input is split at bz2 stream boundaries,
+// and each stream is decompressed and tokenized by a pool of workers. The per-page results are
+// added to the final result in input order, so that the output is the same as for a sequential run.
+
+// each bz2 stream starts with "BZh" + block size digit, followed by the block magic number
+var bz2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+
+// maxStreamSize is the largest bz2 stream handled by the splitter (a multistream dump has ~100 pages per stream).
+// The rest of the input, from the start of a larger stream, is read sequentially.
+var maxStreamSize = 256 * 1024 * 1024
+
+// nextStreamStart returns the position of the first bz2 stream header after position 0 in buf,
+// looking for the block magic from position from, or -1 if no stream header was found
+func nextStreamStart(buf []byte, from int) int {
+	for from < len(buf) {
+		i := bytes.Index(buf[from:], bz2BlockMagic)
+		if i < 0 {
+			return -1
+		}
+		start := from + i - 4
+		if start > 0 && bytes.HasPrefix(buf[start:], []byte("BZh")) && buf[start+3] >= '1' && buf[start+3] <= '9' {
+			return start
+		}
+		from = from + i + 1
+	}
+	return -1
+}
+
+type streamChunk struct {
+	seq    int
+	offset int64 // raw input offset
+	data   []byte
+}
+
+// splitStreams reads raw bz2 data, starting at the input offset, and sends it as separate streams, in input order.
+// If a stream is larger than maxStreamSize (e.g. for a bz2 file that is not a multistream dump), the rest of the
+// input is returned, starting at the beginning of that stream.
+func splitStreams(r io.Reader, offset int64, chunks chan<- streamChunk, done <-chan struct{}) (io.Reader, error) {
+	defer close(chunks)
+	var minFrom = len(bz2BlockMagic) - 1
+	var buf []byte
+	var from = minFrom
+	var seq = 0
+	var block = make([]byte, 1024*1024)
+	send := func(data []byte) bool {
+		select {
+		case chunks <- streamChunk{seq, offset, data}:
+			seq++
+			offset += int64(len(data))
+			return true
+		case <-done:
+			return false
+		}
+	}
+	for {
+		n, err := r.Read(block)
+		buf = append(buf, block[:n]...)
+		for {
+			i := nextStreamStart(buf, from)
+			if i < 0 {
+				break
+			}
+			if !send(buf[:i]) {
+				return nil, nil
+			}
+			buf = append([]byte{}, buf[i:]...)
+			from = minFrom
+		}
+		if len(buf) > maxStreamSize && err == nil {
+			return io.MultiReader(bytes.NewReader(buf), r), nil
+		}
+		if from < len(buf)-len(bz2BlockMagic)+1 {
+			from = len(buf) - len(bz2BlockMagic) + 1
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(buf) > 0 {
+		send(buf)
+	}
+	return nil, nil
+}
+
+type chunkResult struct {
+	seq        int
+	end        int64 // raw input offset after the stream
+	siteInfo   *SiteInfo
+	tk         *tokenizer
+	pages      []pageResult
+	pagesRead  int // including pages not counted
+	lastPageID int
+}
+
+// countStream decompresses and tokenizes the pages of one bz2 stream
+func countStream(chunk streamChunk, opts loadOptions, tk *tokenizer) chunkResult {
+	var result = chunkResult{seq: chunk.seq, end: chunk.offset + int64(len(chunk.data)), tk: tk}
+	decoder := xml.NewDecoder(bzip2.NewReader(bytes.NewReader(chunk.data)))
+	for {
+		t, _ := decoder.Token()
+		if t == nil {
+			break
+		}
+		switch se := t.(type) {
+		case xml.StartElement:
+			if se.Name.Local == "siteinfo" {
+				var si SiteInfo
+				decoder.DecodeElement(&si, &se)
+				var siteResult loadResult
+				siteResult.setSiteInfo(si, opts)
+				result.siteInfo = &siteResult.siteInfo
+				result.tk = siteResult.tk
+			}
+			if se.Name.Local == "page" {
+				p, pr, selected := readPage(decoder, result.tk, opts)
+				result.pagesRead++
+				result.lastPageID = p.ID
+				if selected {
+					result.pages = append(result.pages, pr)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// loadParallel continues to load the dump into result, from the raw input offset
+func loadParallel(path string, opts loadOptions, result loadResult, offset int64) loadResult {
+	rawInput, err := openRawInputAt(path, offset)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rawInput.Close()
+	input := opts.checksum.wrap(rawInput)
+
+	var workers = opts.workers
+	if workers < 1 {
+		workers = 1
+	}
+	var chunks = make(chan streamChunk, workers)
+	var results = make(chan chunkResult, workers)
+	var done = make(chan struct{})
+	var splitErr = make(chan error, 1)
+	var rest io.Reader
+
+	go func() {
+		var err error
+		rest, err = splitStreams(input, offset, chunks, done)
+		splitErr <- err
+	}()
+
+	// the first stream is processed before starting the workers, since it holds the siteinfo needed by the tokenizer
+	var pending = make(map[int]chunkResult)
+	if first, ok := <-chunks; ok {
+		cr := countStream(first, opts, result.tk)
+		if cr.siteInfo != nil {
+			result.siteInfo = *cr.siteInfo
+			result.tk = cr.tk
+		}
+		pending[cr.seq] = cr
+	}
+	var tk = result.tk
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				select {
+				case results <- countStream(chunk, opts, tk):
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var next = 0
+	var limitReached = false
+	var merge = func() {
+		for !limitReached {
+			cr, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			for _, pr := range cr.pages {
+				if pageLimitReached(result, opts.pageLimit) {
+					limitReached = true
+					close(done)
+					break
+				}
+				result.addPage(pr, opts.logAt)
+			}
+			if !limitReached {
+				result.pagesRead += cr.pagesRead
+				if cr.pagesRead > 0 {
+					result.lastPageID = cr.lastPageID
+				}
+				opts.checkpoint.saveIfDue(result, cr.end)
+			}
+		}
+	}
+	merge()
+	for cr := range results {
+		if limitReached {
+			continue
+		}
+		pending[cr.seq] = cr
+		merge()
+	}
+	if err := <-splitErr; err != nil {
+		log.Fatal(err)
+	}
+	if rest != nil && !limitReached {
+		log.Print(fmt.Sprintf("No bz2 stream boundary found in %d bytes (not a multistream dump?), reading the rest of the input sequentially", maxStreamSize))
+		readPages(xml.NewDecoder(bzip2.NewReader(rest)), &result, opts, nil)
+	}
+	if err := opts.checksum.verify(input, path); err != nil {
+		log.Fatal(err)
+	}
+	return result
+}
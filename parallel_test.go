@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNextStreamStart(t *testing.T) {
+	header := append([]byte("BZh9"), bz2BlockMagic...)
+	buf := append(append(append([]byte{}, header...), []byte("xxBZh0")...), header...)
+	if i := nextStreamStart(buf, 5); i != 16 {
+		t.Errorf(fsExp, 16, i)
+	}
+	if i := nextStreamStart(buf[:15], 5); i != -1 {
+		t.Errorf(fsExp, -1, i)
+	}
+}
+
+func TestLoadParallel(t *testing.T) {
+	for _, pageLimit := range []int{-1, 1, 4, 7} {
+		var opts = loadOptions{pageLimit: pageLimit, logAt: 100, workers: 1}
+		expect := loadXML(testDump, opts)
+		opts.workers = 3
+		result := loadXML(testDump, opts)
+		if !sameCounts(result, expect) {
+			t.Errorf(fsExp, expect, result)
+		}
+		if pageLimit > 0 && result.nPages != pageLimit {
+			t.Errorf(fsExp, pageLimit, result.nPages)
+		}
+	}
+}
+
+func TestLoadParallelStreamSize(t *testing.T) {
+	defer func(size int) { maxStreamSize = size }(maxStreamSize)
+	maxStreamSize = 1000
+
+	// a bz2 file with a single stream (not a multistream dump) is read sequentially
+	var opts = loadOptions{pageLimit: -1, logAt: 100, workers: 1}
+	expect := loadXML(testXML, opts)
+	opts.workers = 3
+	result := loadXML("testdata/svwiki-test-pages-articles.xml.bz2", opts)
+	if !sameCounts(result, expect) {
+		t.Errorf(fsExp, expect, result)
+	}
+	if result.siteInfo.DBName != expect.siteInfo.DBName {
+		t.Errorf(fsExp, expect.siteInfo.DBName, result.siteInfo.DBName)
+	}
+
+	// the streams of the test dump are 544, 662, 551, 492 and 55 bytes: the rest of the input is returned from the
+	// start of the second stream
+	data, err := ioutil.ReadFile(testDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxStreamSize = 600
+	var chunks = make(chan streamChunk, 10)
+	rest, err := splitStreams(iotest.OneByteReader(bytes.NewReader(data)), 0, chunks, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	for chunk := range chunks {
+		sizes = append(sizes, len(chunk.data))
+	}
+	if !reflect.DeepEqual(sizes, []int{544}) {
+		t.Errorf(fsExp, []int{544}, sizes)
+	}
+	if rest == nil {
+		t.Fatal("expected the rest of the input")
+	}
+	restData, err := ioutil.ReadAll(rest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restData, data[544:]) {
+		t.Errorf(fsExp, len(data)-544, len(restData))
+	}
+}
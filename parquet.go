@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Parquet export of the frequency lists, with the columns word (or ngram), count, docfreq (document frequency,
+// null if not counted), rank and relfreq (relative frequency). Words with the same count have the same rank
+// (1, 2, 2, 4, ...).
+
+const formatParquet = "parquet"
+
+type parquetRow struct {
+	Key     string
+	Count   int64
+	DocFreq *int64
+	Rank    int64
+	RelFreq float64
+}
+
+func parquetSchema(key string) string {
+	return `{
+  "Tag": "name=parquet_go_root, repetitiontype=REQUIRED",
+  "Fields": [
+    {"Tag": "name=` + key + `, inname=Key, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=REQUIRED"},
+    {"Tag": "name=count, inname=Count, type=INT64, repetitiontype=REQUIRED"},
+    {"Tag": "name=docfreq, inname=DocFreq, type=INT64, repetitiontype=OPTIONAL"},
+    {"Tag": "name=rank, inname=Rank, type=INT64, repetitiontype=REQUIRED"},
+    {"Tag": "name=relfreq, inname=RelFreq, type=DOUBLE, repetitiontype=REQUIRED"}
+  ]
+}`
+}
+
+type parquetWriter struct {
+	pw       *writer.ParquetWriter
+	w        io.WriteCloser
+	total    int            // total count, for the relative frequencies
+	docFreqs map[string]int // nil if not counted
+	n        int            // no. of rows written
+	rank     int
+	last     int // last count
+}
+
+// newParquetWriter creates a parquet file (stdout if path is empty), with key as the name of the first column
+func newParquetWriter(path string, key string, total int, docFreqs map[string]int) (*parquetWriter, error) {
+	var w io.WriteCloser = nopCloser{os.Stdout}
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w = file
+	}
+	pw, err := writer.NewParquetWriterFromWriter(w, parquetSchema(key), 1)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	return &parquetWriter{pw: pw, w: w, total: total, docFreqs: docFreqs, last: -1}, nil
+}
+
+// writeRow writes a row, given the count and the key (any other values are ignored). The rows are expected in
+// frequency order.
+func (pw *parquetWriter) writeRow(values ...interface{}) error {
+	count, ok1 := values[0].(int)
+	key, ok2 := values[1].(string)
+	if !ok1 || !ok2 {
+		return fmt.Errorf("invalid parquet row: %v", values)
+	}
+	pw.n++
+	if count != pw.last {
+		pw.rank = pw.n
+		pw.last = count
+	}
+	var row = parquetRow{Key: key, Count: int64(count), Rank: int64(pw.rank)}
+	if pw.total > 0 {
+		row.RelFreq = float64(count) / float64(pw.total)
+	}
+	if pw.docFreqs != nil {
+		df := int64(pw.docFreqs[key])
+		row.DocFreq = &df
+	}
+	return pw.pw.Write(row)
+}
+
+func (pw *parquetWriter) close() error {
+	if err := pw.pw.WriteStop(); err != nil {
+		pw.w.Close()
+		return err
+	}
+	return pw.w.Close()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// parquetFile is a local file implementing source.ParquetFile, for reading the parquet files in the tests
+type parquetFile struct {
+	*os.File
+}
+
+func (pf parquetFile) Open(name string) (source.ParquetFile, error) {
+	if name == "" {
+		name = pf.Name()
+	}
+	f, err := os.Open(name)
+	return parquetFile{f}, err
+}
+
+func (pf parquetFile) Create(name string) (source.ParquetFile, error) {
+	f, err := os.Create(name)
+	return parquetFile{f}, err
+}
+
+func readParquet(t *testing.T, path string, key string) []parquetRow {
+	pf, err := parquetFile{}.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pf.Close()
+	pr, err := reader.NewParquetReader(pf, parquetSchema(key), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	var rows = make([]parquetRow, pr.GetNumRows())
+	if err := pr.Read(&rows); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestParquet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var opts = loadOptions{pageLimit: -1, logAt: 100, ngramN: 2, docFreqs: true, namespaces: map[int]bool{0: true}}
+	result := loadXML(testXML, opts)
+
+	var out = outputOptions{minFreq: 3, ngramMinFreq: 2, format: formatParquet, file: filepath.Join(dir, "words.parquet"), ngramFile: filepath.Join(dir, "bigrams.parquet")}
+	if _, err := writeWordList(result, opts, out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeNgramList(result, opts, out); err != nil {
+		t.Fatal(err)
+	}
+
+	var words = readParquet(t, out.file, "word")
+	var freqs = sortByWordCount(result.wordFreqs)
+	var nRows = 0
+	for _, pair := range freqs {
+		if pair.Value >= out.minFreq {
+			nRows++
+		}
+	}
+	if len(words) != nRows {
+		t.Fatalf(fsExp, nRows, len(words))
+	}
+	for i, row := range words {
+		if row.Key != freqs[i].Key || int(row.Count) != freqs[i].Value {
+			t.Errorf(fsExp, freqs[i], row)
+		}
+		if row.DocFreq == nil || int(*row.DocFreq) != result.docFreqs[row.Key] {
+			t.Errorf("unexpected docfreq for %s: %v", row.Key, row.DocFreq)
+		}
+		if expect := float64(row.Count) / float64(result.nWords); row.RelFreq != expect {
+			t.Errorf(fsExp, expect, row.RelFreq)
+		}
+		// same count, same rank
+		var expectRank = int64(i + 1)
+		if i > 0 && row.Count == words[i-1].Count {
+			expectRank = words[i-1].Rank
+		}
+		if row.Rank != expectRank {
+			t.Errorf(fsExp, expectRank, row.Rank)
+		}
+	}
+	if words[0].Key != "är" || words[0].Count != 6 || words[0].Rank != 1 {
+		t.Errorf(fsExp, "är 6 1", words[0])
+	}
+
+	var bigrams = readParquet(t, out.ngramFile, "ngram")
+	if len(bigrams) == 0 {
+		t.Fatalf("no bigrams in %s", out.ngramFile)
+	}
+	for _, row := range bigrams {
+		if row.Count < 2 || int(row.Count) != result.ngramFreqs[row.Key] || int(*row.DocFreq) != result.ngramDocFreqs[row.Key] {
+			t.Errorf("unexpected bigram row: %v", row)
+		}
+	}
+
+	// without document frequencies
+	result.docFreqs = nil
+	if _, err := writeWordList(result, opts, out); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range readParquet(t, out.file, "word") {
+		if row.DocFreq != nil {
+			t.Errorf("expected null docfreq, found %d", *row.DocFreq)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The plot subcommand reads a word frequency list (tsv output, sorted by frequency), and writes the lexicon
+// coverage data (no. of words, coverage, word) and an svg with the coverage curve and a log-log rank/frequency
+// (Zipf) plot. The Zipf exponent s (freq ~ 1/rank^s) is fitted by least squares on log-spaced ranks, so that the
+// long tail of low frequency words doesn't dominate the fit.
+
+type coveragePoint struct {
+	nWords   int
+	coverage float64 // percent of the total count
+	word     string
+}
+
+type plotData struct {
+	total    int // total count
+	freqs    []int
+	words    []string
+	ranks    []int           // words with the same count have the same rank (1, 2, 2, 4, ...)
+	coverage []coveragePoint // one point per word
+}
+
+// readFreqList reads a tsv frequency list (see parseFreqLine)
+func readFreqList(r io.Reader) (plotData, error) {
+	var result plotData
+	err := scanFreqs(r, func(key string, f int) {
+		result.total += f
+		result.freqs = append(result.freqs, f)
+		result.words = append(result.words, key)
+	})
+	if err != nil {
+		return result, err
+	}
+	var acc = 0
+	for i, f := range result.freqs {
+		acc += f
+		if i > 0 && f == result.freqs[i-1] {
+			result.ranks = append(result.ranks, result.ranks[i-1])
+		} else {
+			result.ranks = append(result.ranks, i+1)
+		}
+		result.coverage = append(result.coverage, coveragePoint{i + 1, 100 * float64(acc) / float64(result.total), result.words[i]})
+	}
+	return result, nil
+}
+
+// writeCoverage writes the coverage data for every interval words, and for the last word
+func (pd plotData) writeCoverage(w io.Writer, interval int) error {
+	if _, err := fmt.Fprintf(w, "# Text coverage Wikipedia\n# No. wds\t%% Coverage\tWord\n"); err != nil {
+		return err
+	}
+	for i, p := range pd.coverage {
+		if p.nWords%interval == 0 || i == len(pd.coverage)-1 {
+			if _, err := fmt.Fprintf(w, "%d\t%.2f %%\t%s\n", p.nWords, p.coverage, p.word); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// logRanks returns log-spaced ranks (1-based), about perDecade ranks per power of ten, up to n
+func logRanks(n int, perDecade int) []int {
+	var result []int
+	var last = 0
+	for i := 0; ; i++ {
+		r := int(math.Round(math.Pow(10, float64(i)/float64(perDecade))))
+		if r > n {
+			break
+		}
+		if r > last {
+			result = append(result, r)
+			last = r
+		}
+	}
+	if n > 0 && last < n {
+		result = append(result, n)
+	}
+	return result
+}
+
+// zipfFit returns the Zipf exponent s and the log10 intercept c of the least squares fit of
+// log10(freq) = c - s*log10(rank), on the given ranks
+func zipfFit(freqs []int, ranks []int) (s float64, c float64) {
+	var n, sx, sy, sxx, sxy float64
+	for _, r := range ranks {
+		if freqs[r-1] <= 0 {
+			continue
+		}
+		x, y := math.Log10(float64(r)), math.Log10(float64(freqs[r-1]))
+		n++
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	if n < 2 || n*sxx-sx*sx == 0 {
+		return 0, 0
+	}
+	var slope = (n*sxy - sx*sy) / (n*sxx - sx*sx)
+	return -slope, (sy - slope*sx) / n
+}
+
+// start: svg
+
+const (
+	svgPanelWidth  = 480
+	svgPanelHeight = 360
+	svgMargin      = 60
+)
+
+// svgPanel maps data coordinates to the svg coordinates of a panel
+type svgPanel struct {
+	x0, y0                 float64 // top left corner of the plot area
+	xMin, xMax, yMin, yMax float64
+}
+
+func (p svgPanel) width() float64  { return svgPanelWidth - 2*svgMargin }
+func (p svgPanel) height() float64 { return svgPanelHeight - 2*svgMargin }
+
+func (p svgPanel) xy(x, y float64) (float64, float64) {
+	px := p.x0 + (x-p.xMin)/(p.xMax-p.xMin)*p.width()
+	py := p.y0 + p.height() - (y-p.yMin)/(p.yMax-p.yMin)*p.height()
+	return px, py
+}
+
+// point returns the svg coordinates of a data point, for polylines
+func (p svgPanel) point(x, y float64) string {
+	px, py := p.xy(x, y)
+	return fmt.Sprintf("%.1f,%.1f", px, py)
+}
+
+// niceTicks returns about n round tick values from 0 to max
+func niceTicks(max float64, n int) []float64 {
+	if max <= 0 {
+		return []float64{0}
+	}
+	var step = math.Pow(10, math.Floor(math.Log10(max/float64(n))))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if max/(step*m) <= float64(n) {
+			step *= m
+			break
+		}
+	}
+	var result []float64
+	for v := 0.0; v <= max+step/1e6; v += step {
+		result = append(result, v)
+	}
+	return result
+}
+
+func svgEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(s)
+}
+
+// axes draws the axes, with ticks at the data values xTicks and yTicks, labelled by label
+func (p svgPanel) axes(w io.Writer, title, xLabel, yLabel string, xTicks, yTicks []float64, label func(float64) string) {
+	fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle" font-size="16">%s</text>`+"\n", p.x0+p.width()/2, p.y0-20, svgEscape(title))
+	fmt.Fprintf(w, `<polyline points="%s %s %s" fill="none" stroke="black"/>`+"\n", p.point(p.xMin, p.yMax), p.point(p.xMin, p.yMin), p.point(p.xMax, p.yMin))
+	for _, t := range xTicks {
+		x, y := p.xy(t, p.yMin)
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="black"/>`+"\n", x, y, x, y+5)
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", x, y+18, label(t))
+	}
+	for _, t := range yTicks {
+		x, y := p.xy(p.xMin, t)
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="black"/>`+"\n", x-5, y, x, y)
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n", x-8, y, label(t))
+	}
+	fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", p.x0+p.width()/2, p.y0+p.height()+40, svgEscape(xLabel))
+	fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle" transform="rotate(-90 %.1f %.1f)">%s</text>`+"\n",
+		p.x0-45, p.y0+p.height()/2, p.x0-45, p.y0+p.height()/2, svgEscape(yLabel))
+}
+
+func formatTick(v float64) string {
+	if v >= 1000000 {
+		return strconv.FormatFloat(v/1000000, 'f', -1, 64) + "M"
+	}
+	if v >= 1000 {
+		return strconv.FormatFloat(v/1000, 'f', -1, 64) + "k"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// writeSVG writes the coverage plot and the Zipf plot side by side, with the fitted Zipf line
+func (pd plotData) writeSVG(w io.Writer, title string) error {
+	var buf = bufio.NewWriter(w)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", 2*svgPanelWidth, svgPanelHeight+30)
+	fmt.Fprintf(buf, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(buf, `<text x="%d" y="20" text-anchor="middle" font-size="14">%s</text>`+"\n", svgPanelWidth, svgEscape(title))
+	var n = len(pd.freqs)
+
+	// coverage vs. lexicon size, with at most about 1000 points
+	var cov = svgPanel{x0: svgMargin, y0: svgMargin + 30, xMin: 0, xMax: math.Max(float64(n), 1), yMin: 0, yMax: 100}
+	cov.axes(buf, "Lexicon coverage", "No. of words in lexicon", "Coverage (%)", niceTicks(cov.xMax, 5), niceTicks(100, 5), formatTick)
+	var step = n/1000 + 1
+	var points = []string{cov.point(0, 0)}
+	for i, p := range pd.coverage {
+		if i%step == 0 || i == n-1 {
+			points = append(points, cov.point(float64(p.nWords), p.coverage))
+		}
+	}
+	fmt.Fprintf(buf, `<polyline points="%s" fill="none" stroke="blue" stroke-width="1.5"/>`+"\n", strings.Join(points, " "))
+
+	// log-log rank/frequency
+	var ranks = logRanks(n, 20)
+	var s, c = zipfFit(pd.freqs, ranks)
+	var maxFreq = 1
+	if n > 0 {
+		maxFreq = pd.freqs[0]
+	}
+	var zipf = svgPanel{x0: svgPanelWidth + svgMargin, y0: svgMargin + 30, xMin: 0, xMax: math.Max(math.Ceil(math.Log10(float64(n))), 1), yMin: 0, yMax: math.Max(math.Ceil(math.Log10(float64(maxFreq))), 1)}
+	var decades = func(max float64) []float64 {
+		var result []float64
+		for d := 0.0; d <= max; d++ {
+			result = append(result, d)
+		}
+		return result
+	}
+	zipf.axes(buf, fmt.Sprintf("Zipf plot (s = %.3f)", s), "Rank", "Frequency", decades(zipf.xMax), decades(zipf.yMax), func(v float64) string {
+		return formatTick(math.Pow(10, v))
+	})
+	points = nil
+	for _, r := range ranks {
+		if pd.freqs[r-1] > 0 {
+			points = append(points, zipf.point(math.Log10(float64(r)), math.Log10(float64(pd.freqs[r-1]))))
+		}
+	}
+	fmt.Fprintf(buf, `<polyline points="%s" fill="none" stroke="blue" stroke-width="1.5"/>`+"\n", strings.Join(points, " "))
+	if s != 0 {
+		// the fitted line, clipped to the plot area
+		var x1 = math.Min(zipf.xMax, c/s)
+		fmt.Fprintf(buf, `<polyline points="%s %s" fill="none" stroke="red" stroke-dasharray="6,4"/>`+"\n", zipf.point(0, c), zipf.point(x1, c-s*x1))
+	}
+	fmt.Fprintln(buf, "</svg>")
+	return buf.Flush()
+}
+
+// end: svg
+
+// plotDataPath returns the path of the coverage data file, with the .dat extension instead of the extension of
+// the svg file. The svg file can't be the data file, and neither file can be the input file.
+func plotDataPath(input string, svgFile string) (string, error) {
+	var datFile = strings.TrimSuffix(svgFile, filepath.Ext(svgFile)) + ".dat"
+	if filepath.Clean(datFile) == filepath.Clean(svgFile) {
+		return "", fmt.Errorf("the svg file can't have the .dat extension of the coverage data file: %s", svgFile)
+	}
+	for _, p := range []string{datFile, svgFile} {
+		if filepath.Clean(p) == filepath.Clean(input) {
+			return "", fmt.Errorf("the output would overwrite the input file: %s", input)
+		}
+	}
+	return datFile, nil
+}
+
+func plotCmd(args []string) {
+	var usage = `
+wstats plot reads a word frequency list (tsv output of wstats), and writes the lexicon coverage data (<output>.dat)
+and an svg file with the coverage curve and a log-log rank/frequency (Zipf) plot, with the fitted Zipf exponent.
+
+Usage:
+ $ go run . plot <flags> <word frequency list> <output svg file>
+
+Cmd line flags:
+  -interval int  no. of words between the lines of the coverage data (optional, default = 1000)
+  -title string  title of the plot (optional, default = Wikipedia lexicon coverage)
+  -h(elp)        help: print help message
+
+Example usage:
+  $ go run . plot svwiki.freq svwiki-coverage.svg
+
+`
+	var f = flag.NewFlagSet("plot", flag.ExitOnError)
+	var interval = f.Int("interval", 1000, "coverage data interval")
+	var title = f.String("title", "Wikipedia lexicon coverage", "plot title")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage)
+	}
+	if err := f.Parse(args); err != nil || len(f.Args()) != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if *interval < 1 {
+		log.Fatal("Invalid interval: ", *interval)
+	}
+	var input, svgFile = f.Args()[0], f.Args()[1]
+	datFile, err := plotDataPath(input, svgFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := os.Open(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pd, err := readFreqList(r)
+	r.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Input      : ", input)
+	log.Print("Total freq : ", lIntPrettyPrint(pd.total))
+
+	for _, o := range []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{datFile, func(w io.Writer) error { return pd.writeCoverage(w, *interval) }},
+		{svgFile, func(w io.Writer) error { return pd.writeSVG(w, *title) }},
+	} {
+		w, err := os.Create(o.path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := o.write(w); err != nil {
+			w.Close()
+			log.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	s, _ := zipfFit(pd.freqs, logRanks(len(pd.freqs), 20))
+	log.Print("Zipf exp.  : ", fmt.Sprintf("%.3f", s))
+	log.Print("Data       : ", datFile)
+	log.Print("Output     : ", svgFile)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLogRanks(t *testing.T) {
+	var tests = []struct {
+		n         int
+		perDecade int
+		expect    []int
+	}{
+		{0, 10, nil},
+		{1, 10, []int{1}},
+		{12, 4, []int{1, 2, 3, 6, 10, 12}},
+		{100, 2, []int{1, 3, 10, 32, 100}},
+	}
+	for _, test := range tests {
+		result := logRanks(test.n, test.perDecade)
+		if fmt.Sprint(result) != fmt.Sprint(test.expect) {
+			t.Errorf(fsExp, test.expect, result)
+		}
+	}
+}
+
+func TestZipfFit(t *testing.T) {
+	for _, expect := range []float64{0.8, 1.0, 1.2} {
+		var freqs []int
+		for r := 1; r <= 100000; r++ {
+			freqs = append(freqs, int(math.Round(1e7/math.Pow(float64(r), expect))))
+		}
+		s, c := zipfFit(freqs, logRanks(len(freqs), 20))
+		if math.Abs(s-expect) > 0.01 || math.Abs(c-7) > 0.01 {
+			t.Errorf(fsExp, fmt.Sprintf("%.2f 7.00", expect), fmt.Sprintf("%.2f %.2f", s, c))
+		}
+	}
+}
+
+func TestPlot(t *testing.T) {
+	var input = "5\toch\n3\ti\n1\tatt\t0.5\n1\tär\n"
+	pd, err := readFreqList(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := pd.writeCoverage(&buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	var expect = "# Text coverage Wikipedia\n# No. wds\t% Coverage\tWord\n2\t80.00 %\ti\n4\t100.00 %\tär\n"
+	if buf.String() != expect {
+		t.Errorf(fsExp, expect, buf.String())
+	}
+
+	buf.Reset()
+	if err := pd.writeSVG(&buf, "Test & <title>"); err != nil {
+		t.Fatal(err)
+	}
+	var svg struct {
+		XMLName   xml.Name
+		Polylines []struct {
+			Points string `xml:"points,attr"`
+		} `xml:"polyline"`
+		Texts []string `xml:"text"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("invalid svg: %v\n%s", err, buf.String())
+	}
+	if svg.XMLName.Local != "svg" || len(svg.Polylines) != 5 {
+		t.Errorf("expected svg with axes, curves and fitted line, found %s with %d polylines", svg.XMLName.Local, len(svg.Polylines))
+	}
+	if !contains(svg.Texts, "Test & <title>") {
+		t.Errorf("title not found in %v", svg.Texts)
+	}
+
+	if _, err := readFreqList(strings.NewReader("och\t5\n")); err == nil {
+		t.Errorf("expected error for invalid frequency list")
+	}
+
+	// a line longer than the default scanner buffer (64 KB)
+	var long = strings.Repeat("x", 100*1024)
+	if pd, err := readFreqList(strings.NewReader("5\toch\n1\t" + long + "\n")); err != nil {
+		t.Error(err)
+	} else if len(pd.words) != 2 || pd.words[1] != long {
+		t.Errorf("expected the long line to be read, found %d words", len(pd.words))
+	}
+}
+
+func TestPlotDataPath(t *testing.T) {
+	tests := []struct {
+		input  string
+		svg    string
+		expect string // empty for an error
+	}{
+		{"svwiki.freq", "svwiki-coverage.svg", "svwiki-coverage.dat"},
+		{"svwiki.freq", "plots/coverage", "plots/coverage.dat"},
+		{"svwiki.freq", "coverage.dat", ""},
+		{"svwiki.freq", "./coverage.x/../coverage.dat", ""},
+		{"svwiki.dat", "svwiki.svg", ""},
+		{"svwiki.freq", "svwiki.freq", ""},
+	}
+	for _, test := range tests {
+		result, err := plotDataPath(test.input, test.svg)
+		if test.expect == "" && err == nil {
+			t.Errorf("expected an error for %s and %s, got %s", test.input, test.svg, result)
+		} else if test.expect != "" && (err != nil || result != test.expect) {
+			t.Errorf(fsExp, test.expect, result)
+		}
+	}
+}
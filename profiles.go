@@ -0,0 +1,189 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Language profiles for the tokenizer: the rules for apostrophes, hyphens, colons and abbreviations that differ
+// between languages. The profile is set by -lang, or inferred from the dbname of the siteinfo header (e.g. svwiki
+// for sv). The generic profile, used for other languages, has the rules of the Swedish profile, except for
+// suspended hyphens and abbreviations:
+//   - apostrophes within words are kept (Bentley's, d'Holbach), but not at the start or end of a word
+//   - hyphens within words are kept (1700-talet), but not at the start or end of a word
+//   - colons within words are kept (EU:n, S:t)
+//   - periods are removed (t.ex. is split into t and ex)
+
+const langGeneric = "generic"
+
+// abbreviationDot and suspendedHyphen are placeholders (private use characters) for the periods of abbreviations
+// and for suspended hyphens, so that they are not removed with the other punctuation. The placeholder characters
+// are removed from the input before marking (see markAbbreviations).
+const (
+	abbreviationDot = "\uE000"
+	suspendedHyphen = "\uE001"
+)
+
+var placeholderRemover = strings.NewReplacer(abbreviationDot, "", suspendedHyphen, "")
+
+type langProfile struct {
+	name          string
+	apostrophes   string   // other characters used as apostrophes, counted as ' (e.g. ’ in don’t)
+	elisions      []string // elided words split off from the following word (the l' of l'homme)
+	splitColons   bool     // split words at colons (otherwise kept within words, as in EU:n)
+	conjunctions  []string // conjunctions after which a word final hyphen is kept (sjö- och flygräddning)
+	abbreviations []string // abbreviations that keep their periods, and do not end a sentence
+}
+
+var langProfiles = map[string]langProfile{
+	langGeneric: {name: langGeneric},
+	"sv": {
+		name:          "sv",
+		conjunctions:  []string{"och", "eller", "samt"},
+		abbreviations: []string{"t.ex.", "bl.a.", "s.k.", "m.m.", "d.v.s.", "dvs.", "o.s.v.", "osv.", "f.d.", "f.Kr.", "e.Kr.", "ca.", "kl.", "resp.", "jfr.", "fr.o.m.", "t.o.m.", "p.g.a."},
+	},
+	"fi": {
+		name:          "fi",
+		conjunctions:  []string{"ja", "tai", "sekä"},
+		abbreviations: []string{"esim.", "mm.", "ns.", "jne.", "yms.", "ks.", "vrt.", "eaa.", "jaa.", "eKr.", "jKr."},
+	},
+	"en": {
+		name:          "en",
+		apostrophes:   "’ʼ",
+		splitColons:   true,
+		abbreviations: []string{"e.g.", "i.e.", "etc.", "cf.", "vs.", "Mr.", "Mrs.", "Dr.", "St.", "Jr.", "U.S.", "a.m.", "p.m."},
+	},
+	"fr": {
+		name:          "fr",
+		apostrophes:   "’ʼ",
+		elisions:      []string{"c", "d", "j", "l", "m", "n", "s", "t", "qu", "jusqu", "lorsqu", "puisqu", "quoiqu"},
+		splitColons:   true,
+		abbreviations: []string{"cf.", "etc.", "env.", "av.", "apr.", "J.-C.", "p.ex.", "c.-à-d."},
+	},
+}
+
+// langNames returns the names of the profiles, in alphabetical order
+func langNames() []string {
+	var result []string
+	for name := range langProfiles {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+var dbNameRe = regexp.MustCompile("^([a-z_]+?)(wiki|wiktionary|wikibooks|wikinews|wikiquote|wikisource|wikiversity|wikivoyage)$")
+
+// dbNameLang returns the language code of a wiki dbname (e.g. sv for svwiki), or an empty string
+func dbNameLang(dbName string) string {
+	if m := dbNameRe.FindStringSubmatch(dbName); m != nil {
+		return strings.Replace(m[1], "_", "-", -1)
+	}
+	return ""
+}
+
+// profileFor returns the named profile, or if lang is empty, the profile of the dbname of the wiki. The generic
+// profile is used for languages without a profile.
+func profileFor(lang string, si SiteInfo) langProfile {
+	if lang == "" {
+		lang = dbNameLang(si.DBName)
+	}
+	if lp, ok := langProfiles[lang]; ok {
+		return lp
+	}
+	return langProfiles[langGeneric]
+}
+
+func quoteAll(ss []string) []string {
+	var result []string
+	for _, s := range ss {
+		result = append(result, regexp.QuoteMeta(s))
+	}
+	return result
+}
+
+// replacements returns the punctuation replacements of the profile, to be used before (pre) and after (post) the
+// generic punctuation replacements
+func (lp langProfile) replacements() (pre []replacement, post []replacement) {
+	if lp.apostrophes != "" {
+		pre = append(pre, replacement{regexp.MustCompile("[" + lp.apostrophes + "]"), "'"})
+	}
+	if lp.splitColons {
+		pre = append(pre, replacement{regexp.MustCompile("(\\pL):"), "$1 "})
+	}
+	if len(lp.conjunctions) > 0 {
+		pre = append(pre, replacement{regexp.MustCompile("([\\pL\\pN])-( +(?:" + strings.Join(lp.conjunctions, "|") + ") )"), "$1" + suspendedHyphen + "$2"})
+	}
+	if len(lp.elisions) > 0 {
+		post = append(post, replacement{regexp.MustCompile("(?i)(^| )(" + strings.Join(lp.elisions, "|") + ")'(\\pL)"), "$1$2' $3"})
+	}
+	if len(lp.conjunctions) > 0 {
+		post = append(post, replacement{regexp.MustCompile(suspendedHyphen), "-"})
+	}
+	if len(lp.abbreviations) > 0 {
+		post = append(post, replacement{regexp.MustCompile(abbreviationDot), "."})
+	}
+	return pre, post
+}
+
+// abbreviationRe returns a regexp matching the abbreviations of the profile, or nil if there are none
+func (lp langProfile) abbreviationRe() *regexp.Regexp {
+	if len(lp.abbreviations) == 0 {
+		return nil
+	}
+	return regexp.MustCompile("(?i)(^|[^\\pL\\pN.])(" + strings.Join(quoteAll(lp.abbreviations), "|") + ")")
+}
+
+// abbreviationSet returns the lower case abbreviations of the profile
+func (lp langProfile) abbreviationSet() map[string]bool {
+	var result = make(map[string]bool)
+	for _, a := range lp.abbreviations {
+		result[strings.ToLower(a)] = true
+	}
+	return result
+}
+
+// markAbbreviations removes the placeholder characters from s, and replaces the periods of the abbreviations in s by
+// placeholders (see langProfile.replacements)
+func (tk *tokenizer) markAbbreviations(s string) string {
+	s = placeholderRemover.Replace(s)
+	if tk.abbreviationRe == nil {
+		return s
+	}
+	return tk.abbreviationRe.ReplaceAllStringFunc(s, func(m string) string {
+		return strings.Replace(m, ".", abbreviationDot, -1)
+	})
+}
+
+// tailor applies the apostrophe, elision and colon rules of the profile to words from the uax29 tokenizer, which
+// keeps apostrophes and colons between letters within words
+func (lp langProfile) tailor(words []string) []string {
+	if lp.apostrophes == "" && len(lp.elisions) == 0 && !lp.splitColons {
+		return words
+	}
+	var elisions = make(map[string]bool)
+	for _, e := range lp.elisions {
+		elisions[e] = true
+	}
+	var result []string
+	for _, w := range words {
+		for _, r := range lp.apostrophes {
+			w = strings.Replace(w, string(r), "'", -1)
+		}
+		if i := strings.Index(w, "'"); i > 0 && elisions[w[:i]] && i+1 < len(w) {
+			result = append(result, w[:i+1])
+			w = w[i+1:]
+		}
+		if lp.splitColons {
+			for _, part := range strings.Split(w, ":") {
+				if part != "" {
+					result = append(result, part)
+				}
+			}
+			continue
+		}
+		result = append(result, w)
+	}
+	return result
+}
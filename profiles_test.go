@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testConvertLang(lang string, input string) string {
+	tk := newTokenizer(defaultSiteInfo, tokenizerOptions{lang: lang})
+	input = tk.preFilterLine(input)
+	if tk.skip(input) {
+		return ""
+	}
+	return tk.convert(input)
+}
+
+func TestAllProfiles(t *testing.T) {
+	tests := map[string]map[string]string{
+		langGeneric: {
+			"Bentley's Miscellany av Baron d'Holbach":     "bentley's miscellany av baron d'holbach",
+			"'citat' och 'Holbach'":                       "citat och holbach",
+			"EU:n och S:t Petersburg":                     "eu:n och s:t petersburg",
+			"under 1700-talet - och 1800- och 1900-talet": "under 1700-talet och 1800 och 1900-talet",
+			"t.ex. bl.a. [[Sverige]]s huvudstad.":         "t ex bl a sveriges huvudstad",
+			"ett\uE000två och\uE001tre":                   "etttvå ochtre",
+		},
+		"sv": {
+			"Bentley's Miscellany av Baron d'Holbach":     "bentley's miscellany av baron d'holbach",
+			"EU:n och S:t Petersburg":                     "eu:n och s:t petersburg",
+			"under 1700-talet - och 1800- och 1900-talet": "under 1700-talet och 1800- och 1900-talet",
+			"[[sjöräddning|Sjö]]- eller flygräddning":     "sjö- eller flygräddning",
+			"t.ex. bl.a. [[Sverige]]s huvudstad.":         "t.ex. bl.a. sveriges huvudstad",
+			"Han föddes 50 f.Kr. i Rom, dvs. i Italien.":  "han föddes 50 f.kr. i rom dvs. i italien",
+			"Den s.k. '''kulturen'''.":                    "den s.k. kulturen",
+			"ett\uE000två och\uE001tre":                   "etttvå ochtre",
+		},
+		"fi": {
+			"EU:n ja Nato:n jäsen":             "eu:n ja nato:n jäsen",
+			"vaa'an punnukset":                 "vaa'an punnukset",
+			"kauko- ja lähiliikenne":           "kauko- ja lähiliikenne",
+			"esim. [[Helsinki]] ja Espoo jne.": "esim. helsinki ja espoo jne.",
+			"1900-luvulla - ja sen jälkeen":    "1900-luvulla ja sen jälkeen",
+		},
+		"en": {
+			"Don’t stop, it's Bentley's":                "don't stop it's bentley's",
+			"The students' ''[[Union]]''":               "the students union",
+			"Wikipedia:About and EU:n":                  "wikipedia about and eu n",
+			"at 10:30 a.m. in the U.S. e.g. [[London]]": "at 10:30 a.m. in the u.s. e.g. london",
+			"a well-known - and long - story":           "a well-known and long story",
+			"Mr. Smith and Dr. Jones, etc.":             "mr. smith and dr. jones etc.",
+		},
+		"fr": {
+			"L'homme d’affaires qu'il connaît":  "l' homme d' affaires qu' il connaît",
+			"jusqu'à [[Paris]], aujourd'hui":    "jusqu' à paris aujourd'hui",
+			"Il dit : « peut-être »":            "il dit peut-être",
+			"en 52 av. J.-C., c.-à-d. la Gaule": "en 52 av. j.-c. c.-à-d. la gaule",
+			"Note:voir":                         "note voir",
+		},
+	}
+
+	var n = 0
+	for lang, langTests := range tests {
+		for input, expect := range langTests {
+			n++
+			result := testConvertLang(lang, input)
+			if result != expect {
+				t.Errorf("%s: "+fsExp, lang, expect, result)
+			}
+		}
+	}
+	fmt.Fprint(os.Stderr, "[profiles_test] ", n, " tests run\n")
+}
+
+func TestProfileFor(t *testing.T) {
+	var tests = []struct {
+		lang   string
+		dbName string
+		expect string
+	}{
+		{"", "svwiki", "sv"},
+		{"", "fiwiktionary", "fi"},
+		{"", "enwiki", "en"},
+		{"", "frwikisource", "fr"},
+		{"", "elwiki", langGeneric},
+		{"", "", langGeneric},
+		{"en", "svwiki", "en"},
+		{langGeneric, "svwiki", langGeneric},
+	}
+	for _, test := range tests {
+		result := profileFor(test.lang, SiteInfo{DBName: test.dbName}).name
+		if result != test.expect {
+			t.Errorf(fsExp, test.expect, result)
+		}
+	}
+	if result := dbNameLang("be_x_oldwiki"); result != "be-x-old" {
+		t.Errorf(fsExp, "be-x-old", result)
+	}
+}
+
+func TestSplitSentencesAbbreviations(t *testing.T) {
+	var tests = map[string][]string{
+		"sv": {"Han föddes 50 f.Kr. I Rom. ", "Han föddes 50 f.Kr. I Rom. "},
+		"en": {"Mr. Smith came. He left.", "Mr. Smith came. | He left."},
+	}
+	for lang, test := range tests {
+		var tk = newTokenizer(defaultSiteInfo, tokenizerOptions{lang: lang})
+		var result = strings.Join(splitSentences(test[0], tk.abbreviations), "| ")
+		if result != test[1] {
+			t.Errorf("%s: "+fsExp, lang, test[1], result)
+		}
+	}
+}
+
+func TestTailorUAX29(t *testing.T) {
+	var tests = []struct {
+		lang   string
+		expect []string
+	}{
+		{"sv", []string{"don’t", "l'homme", "eu:n"}},
+		{"en", []string{"don't", "l'homme", "eu", "n"}},
+		{"fr", []string{"don't", "l'", "homme", "eu", "n"}},
+	}
+	for _, test := range tests {
+		var tk = newTokenizer(defaultSiteInfo, tokenizerOptions{tokenizer: tokenizerUAX29, lang: test.lang})
+		var result = tk.tokenizePlainLine("Don’t l'homme EU:n")
+		if !reflect.DeepEqual(result, test.expect) {
+			t.Errorf("%s: "+fsExp, test.lang, test.expect, result)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An unsupervised sentence splitter in the style of Punkt (Kiss & Strunk, 2006), trained on the plain text of the
+// first pages of the dump. Abbreviations are learned as the word types that are (almost) always followed by a
+// period, using a log-likelihood ratio scaled by the length of the word, the no. of internal periods, and the
+// no. of occurrences without period. The orthographic context of each word type (if it has been seen capitalised
+// or lower case, at the start of a sentence or within a sentence) is then used to decide if a period after an
+// abbreviation, an initial or a number ends a sentence. Other periods, and ! ? … end a sentence. The collocation
+// and frequent sentence starter heuristics of Punkt are not used.
+
+const (
+	sentencesRules = "rules"
+	sentencesPunkt = "punkt"
+
+	punktAbbrevScore = 0.3 // min score for an abbreviation
+)
+
+// orthographic context flags
+const (
+	orthoBegUC = 1 << iota
+	orthoMidUC
+	orthoBegLC
+	orthoMidLC
+	orthoUC = orthoBegUC | orthoMidUC
+	orthoLC = orthoBegLC | orthoMidLC
+)
+
+// punktModel is the trained model (saved as JSON with -punktmodel)
+type punktModel struct {
+	Pages         int             `json:"pages"`  // no. of training pages
+	Tokens        int             `json:"tokens"` // no. of training tokens
+	Abbreviations map[string]bool `json:"abbreviations"`
+	Ortho         map[string]int  `json:"ortho"` // orthographic context flags per lower case word type
+}
+
+// punktToken splits a white space delimited token into its word type (lower case, without the final period and
+// surrounding punctuation), the original word, and the sentence final punctuation (if any)
+func punktToken(tok string) (typ string, word string, final string) {
+	word = strings.TrimLeft(tok, "\"“”«»'‘’([")
+	word = strings.TrimRight(word, "\"“”«»'‘’)],;:")
+	var end = len(word)
+	for end > 0 {
+		r, size := utf8.DecodeLastRuneInString(word[:end])
+		if !strings.ContainsRune(".!?…", r) {
+			break
+		}
+		end -= size
+	}
+	final = word[end:]
+	word = word[:end]
+	return strings.ToLower(word), word, final
+}
+
+func isAlphaWord(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumberWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) && r != '.' && r != ',' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// isInitial is true for a single letter
+func isInitial(s string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return size == len(s) && unicode.IsLetter(r)
+}
+
+// punktTrainer collects the training text
+type punktTrainer struct {
+	lines    []string
+	pages    int
+	tokens   int
+	nPeriods int            // no. of tokens with a final period
+	counts   map[string]int // no. of tokens of each type without final period
+	pCounts  map[string]int // no. of tokens of each type with final period
+}
+
+func newPunktTrainer() *punktTrainer {
+	return &punktTrainer{counts: make(map[string]int), pCounts: make(map[string]int)}
+}
+
+// addText adds the lines of plain text
+func (pt *punktTrainer) addText(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		var found = false
+		for _, tok := range strings.Fields(line) {
+			typ, _, final := punktToken(tok)
+			if typ == "" {
+				continue
+			}
+			found = true
+			pt.tokens++
+			if final == "." {
+				pt.nPeriods++
+				pt.pCounts[typ]++
+			} else {
+				pt.counts[typ]++
+			}
+		}
+		if found {
+			pt.lines = append(pt.lines, line)
+		}
+	}
+}
+
+// dunningLogLikelihood returns the log-likelihood of a type (with count a) being followed by a period (count ab
+// times), comparing the overall probability of a period (b periods of n tokens) with the probability 0.99
+func dunningLogLikelihood(a, b, ab, n int) float64 {
+	var p2 = 0.99
+	// p1 is clamped as p2, since log(1-p1) is -Inf (and the score NaN) if every token ends in a period
+	var p1 = math.Min(float64(b)/float64(n), p2)
+	var null = float64(ab)*math.Log(p1) + float64(a-ab)*math.Log(1-p1)
+	var alt = float64(ab)*math.Log(p2) + float64(a-ab)*math.Log(1-p2)
+	return -2 * (null - alt)
+}
+
+// abbreviationScore returns the Punkt abbreviation score of a type
+func (pt *punktTrainer) abbreviationScore(typ string) float64 {
+	var withPeriod, withoutPeriod = pt.pCounts[typ], pt.counts[typ]
+	var nPeriods = strings.Count(typ, ".") + 1
+	var nNonPeriods = utf8.RuneCountInString(typ) - nPeriods + 1
+	var ll = dunningLogLikelihood(withPeriod+withoutPeriod, pt.nPeriods, withPeriod, pt.tokens)
+	var fLength = math.Exp(-float64(nNonPeriods))
+	var fPenalty = math.Pow(float64(nNonPeriods), -float64(withoutPeriod))
+	return ll * fLength * float64(nPeriods) * fPenalty
+}
+
+// train returns the model: the abbreviations, and the orthographic context of the words, using the sentence
+// boundaries given by the abbreviations
+func (pt *punktTrainer) train() *punktModel {
+	var m = &punktModel{Pages: pt.pages, Tokens: pt.tokens, Abbreviations: make(map[string]bool), Ortho: make(map[string]int)}
+	if pt.tokens == 0 || pt.nPeriods == 0 {
+		return m
+	}
+	for typ := range pt.pCounts {
+		if isAlphaWord(typ) && pt.abbreviationScore(typ) >= punktAbbrevScore {
+			m.Abbreviations[typ] = true
+		}
+	}
+	for _, line := range pt.lines {
+		// the first word of a line (paragraph) starts a sentence
+		var sentenceStart, known = true, true
+		for _, tok := range strings.Fields(line) {
+			typ, word, final := punktToken(tok)
+			if typ == "" {
+				continue
+			}
+			if known && isAlphaWord(word) {
+				var upper = isCapitalised(word)
+				switch {
+				case sentenceStart && upper:
+					m.Ortho[typ] |= orthoBegUC
+				case sentenceStart:
+					m.Ortho[typ] |= orthoBegLC
+				case upper:
+					m.Ortho[typ] |= orthoMidUC
+				default:
+					m.Ortho[typ] |= orthoMidLC
+				}
+			}
+			// after an abbreviation, an initial or a number, the next word may or may not start a sentence
+			sentenceStart = final != ""
+			known = final != "." || !(m.Abbreviations[typ] || isInitial(typ) || isNumberWord(typ))
+		}
+	}
+	return m
+}
+
+// orthoHeuristic returns 1 if the word is likely to start a sentence, -1 if not, and 0 if unknown
+func (m *punktModel) orthoHeuristic(word string) int {
+	if word == "" || !isAlphaWord(word) {
+		return -1
+	}
+	var ortho = m.Ortho[strings.ToLower(word)]
+	if isCapitalised(word) {
+		if ortho&orthoLC != 0 && ortho&orthoMidUC == 0 {
+			return 1
+		}
+		return 0
+	}
+	if ortho&orthoUC != 0 || ortho&orthoBegLC == 0 {
+		return -1
+	}
+	return 0
+}
+
+// isBoundary decides if the token, followed by the next token, ends a sentence. The abbreviations of the language
+// profile (with final period) are used together with the learned abbreviations.
+func (m *punktModel) isBoundary(tok string, next string, abbreviations map[string]bool) bool {
+	typ, _, final := punktToken(tok)
+	_, nextWord, _ := punktToken(next)
+	if final == "" {
+		return false
+	}
+	if final != "." {
+		return m.orthoHeuristic(nextWord) != -1
+	}
+	switch {
+	case m.Abbreviations[typ] || abbreviations[typ+"."] || isInitial(typ):
+		return m.orthoHeuristic(nextWord) == 1
+	case isNumberWord(typ):
+		return m.orthoHeuristic(nextWord) != -1
+	}
+	return true
+}
+
+// split splits a line of plain text into sentences
+func (m *punktModel) split(line string, abbreviations map[string]bool) []string {
+	var result []string
+	var start = 0
+	for _, match := range sentenceEndRe.FindAllStringIndex(line, -1) {
+		var prev = strings.TrimRightFunc(line[:match[1]], unicode.IsSpace)
+		var tok = prev[strings.LastIndexFunc(prev, unicode.IsSpace)+1:]
+		var next = strings.Fields(line[match[1]:])
+		if len(next) > 0 && m.isBoundary(tok, next[0], abbreviations) {
+			result = append(result, line[start:match[1]])
+			start = match[1]
+		}
+	}
+	if start < len(line) {
+		result = append(result, line[start:])
+	}
+	return result
+}
+
+// abbreviationList returns the learned abbreviations, in alphabetical order
+func (m *punktModel) abbreviationList() []string {
+	var result []string
+	for a := range m.Abbreviations {
+		result = append(result, a)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// trainPunkt trains a model on the plain text of the first pages of the dump (in the namespaces counted, and
+// not redirects)
+func trainPunkt(path string, opts loadOptions, nPages int) (*punktModel, error) {
+	input, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	var pt = newPunktTrainer()
+	var tk = newTokenizer(defaultSiteInfo, opts.tkOpts)
+	var decoder = xml.NewDecoder(input)
+	for pt.pages < nPages {
+		t, _ := decoder.Token()
+		if t == nil {
+			break
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Local == "siteinfo" {
+			var si SiteInfo
+			decoder.DecodeElement(&si, &se)
+			si.Aliases = append(si.Aliases, opts.nsAliases...)
+			si.ImageOptions = append(si.ImageOptions, opts.imageOpts...)
+			tk = newTokenizer(si, opts.tkOpts)
+		}
+		if se.Name.Local == "page" {
+			// only the text of the latest revision is kept
+			var text *string
+			decodePage(decoder, func(p Page, rev Revision) bool {
+				if (opts.namespaces != nil && !opts.namespaces[p.NS]) || p.Redir.Title != "" {
+					return false
+				}
+				text = &rev.Text
+				return true
+			})
+			if text == nil {
+				continue
+			}
+			pt.pages++
+			pt.addText(tk.parseWikitext(*text))
+		}
+	}
+	return pt.train(), nil
+}
+
+func readPunktModel(path string) (*punktModel, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m punktModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid punkt model %s: %v", path, err)
+	}
+	return &m, nil
+}
+
+func (m *punktModel) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// loadPunktModel reads the model file if it exists, or else trains a model on the dump, and saves it to the model
+// file (if set)
+func loadPunktModel(modelFile string, path string, opts loadOptions, nPages int) (*punktModel, error) {
+	if modelFile != "" {
+		if _, err := os.Stat(modelFile); err == nil {
+			return readPunktModel(modelFile)
+		}
+	}
+	m, err := trainPunkt(path, opts, nPages)
+	if err != nil {
+		return nil, err
+	}
+	if modelFile != "" {
+		return m, m.write(modelFile)
+	}
+	return m, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// punktTestText returns paragraphs of random sentences, with the abbreviations followed by lower case words,
+// and names with initials
+func punktTestText() string {
+	var r = rand.New(rand.NewSource(1))
+	var words = strings.Fields("staden hamnen kyrkan ön bron skolan huset vägen floden parken slottet torget muren")
+	var verbs = strings.Fields("har ligger byggdes finns syns heter växte")
+	var starts = strings.Fields("Den Det Här Sedan Där Även Staden Kyrkan")
+	var word = func() string { return words[r.Intn(len(words))] }
+	var verb = func() string { return verbs[r.Intn(len(verbs))] }
+	var paragraphs []string
+	for p := 0; p < 200; p++ {
+		var sentences []string
+		for s := 0; s < 5; s++ {
+			var sentence = []string{starts[r.Intn(len(starts))], verb(), word()}
+			switch r.Intn(5) {
+			case 0:
+				sentence = append(sentence, "bl.a.", word(), "och", word())
+			case 1:
+				sentence = append(sentence, "ca.", "tre", word())
+			case 2:
+				sentence = append(sentence, "t.ex.", word())
+			case 3:
+				sentence = append(sentence, "vid", "J.", "Svensson", "och", word())
+			}
+			sentences = append(sentences, strings.Join(sentence, " ")+" "+word()+".")
+		}
+		paragraphs = append(paragraphs, strings.Join(sentences, " "))
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+func TestPunktTrain(t *testing.T) {
+	var pt = newPunktTrainer()
+	pt.addText(punktTestText())
+	var m = pt.train()
+	for _, a := range []string{"bl.a", "ca", "t.ex"} {
+		if !m.Abbreviations[a] {
+			t.Errorf("expected abbreviation %s, found %v", a, m.abbreviationList())
+		}
+	}
+	for _, w := range []string{"staden", "kyrkan", "svensson", "och"} {
+		if m.Abbreviations[w] {
+			t.Errorf("unexpected abbreviation %s", w)
+		}
+	}
+
+	var tests = map[string][]string{
+		"Den har bl.a. en hamn. Staden växte.":           {"Den har bl.a. en hamn. ", "Staden växte."},
+		"Det finns ca. tre broar. Sedan byggdes kyrkan.": {"Det finns ca. tre broar. ", "Sedan byggdes kyrkan."},
+		"Staden ligger vid J. Svensson och torget.":      {"Staden ligger vid J. Svensson och torget."},
+		"Där finns t.ex. bron. Här ligger ön!":           {"Där finns t.ex. bron. ", "Här ligger ön!"},
+		"Den ligger vid floden t.ex. Staden växte.":      {"Den ligger vid floden t.ex. ", "Staden växte."},
+		"Den ligger vid t.ex. Svensson.":                 {"Den ligger vid t.ex. Svensson."},
+	}
+	for input, expect := range tests {
+		result := m.split(input, nil)
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+}
+
+func TestPunktTrainAllPeriods(t *testing.T) {
+	// every token ends in a period
+	var pt = newPunktTrainer()
+	pt.addText("Staden. Kyrkan. Bl.a. Hamnen.")
+	var m = pt.train()
+	for _, typ := range []string{"staden", "kyrkan", "bl.a", "hamnen"} {
+		if score := pt.abbreviationScore(typ); math.IsNaN(score) || math.IsInf(score, 0) {
+			t.Errorf("%s: expected a score, got %v", typ, score)
+		}
+	}
+	if len(m.Abbreviations) != 0 {
+		t.Errorf("expected no abbreviations, found %v", m.abbreviationList())
+	}
+}
+
+func TestPunktToken(t *testing.T) {
+	var tests = map[string][3]string{
+		"bl.a.":     {"bl.a", "bl.a", "."},
+		"(Amager.)": {"amager", "Amager", "."},
+		"stor!\"":   {"stor", "stor", "!"},
+		"Svensson,": {"svensson", "Svensson", ""},
+		"vänta…":    {"vänta", "vänta", "…"},
+	}
+	for input, expect := range tests {
+		typ, word, final := punktToken(input)
+		if result := [3]string{typ, word, final}; result != expect {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+}
+
+func TestSentenceStats(t *testing.T) {
+	n, mean, median, p90, max := sentenceStats(map[int]int{2: 1, 3: 5, 10: 3, 40: 1})
+	if n != 10 || mean != 8.7 || median != 3 || p90 != 10 || max != 40 {
+		t.Errorf("unexpected sentence stats: %d %v %d %d %d", n, mean, median, p90, max)
+	}
+	if n, _, _, _, _ := sentenceStats(nil); n != 0 {
+		t.Errorf(fsExp, 0, n)
+	}
+}
+
+func TestLoadSentences(t *testing.T) {
+	var opts = loadOptions{pageLimit: -1, logAt: 100}
+	var rules = loadXML(testXML, opts)
+
+	m, err := trainPunkt(testXML, opts, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Pages == 0 || m.Tokens == 0 {
+		t.Errorf("unexpected punkt model: %d pages, %d tokens", m.Pages, m.Tokens)
+	}
+	opts.tkOpts.sentences = sentencesPunkt
+	opts.tkOpts.punkt = m
+	var punkt = loadXML(testXML, opts)
+	for _, result := range []loadResult{rules, punkt} {
+		var nWords = 0
+		for l, n := range result.sentLengths {
+			nWords += l * n
+		}
+		if nWords != result.nWords {
+			t.Errorf(fsExp, result.nWords, nWords)
+		}
+	}
+	if !reflect.DeepEqual(punkt.wordFreqs, rules.wordFreqs) {
+		t.Errorf("expected the same word counts with the punkt sentence splitter")
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// The query subcommand looks up words in a word frequency list (tsv output), and prints the count, rank,
+// frequency per million words, and the coverage of the list up to the rank of the word.
+
+type queryResult struct {
+	word       string
+	count      int
+	rank       int     // 0 if not found
+	perMillion float64 // count per million words
+	coverage   float64 // percent of the total count covered by the words up to this rank
+}
+
+// wordIndex returns the position of each word in the list (the first, if a word occurs more than once)
+func (pd plotData) wordIndex() map[string]int {
+	var result = make(map[string]int, len(pd.words))
+	for i, w := range pd.words {
+		if _, ok := result[w]; !ok {
+			result[w] = i
+		}
+	}
+	return result
+}
+
+func (pd plotData) queryResult(i int) queryResult {
+	var r = queryResult{word: pd.words[i], count: pd.freqs[i], rank: pd.ranks[i], coverage: pd.coverage[i].coverage}
+	if pd.total > 0 {
+		r.perMillion = 1000000 * float64(r.count) / float64(pd.total)
+	}
+	return r
+}
+
+// query looks up the words. Words not in the list are returned with count and rank 0.
+func (pd plotData) query(words []string) []queryResult {
+	var index = pd.wordIndex()
+	var result []queryResult
+	for _, w := range words {
+		if i, ok := index[w]; ok {
+			result = append(result, pd.queryResult(i))
+		} else {
+			result = append(result, queryResult{word: w})
+		}
+	}
+	return result
+}
+
+// queryRegexp returns the words matching the regexp, in frequency order, at most limit words (all if limit < 1)
+func (pd plotData) queryRegexp(re *regexp.Regexp, limit int) []queryResult {
+	var result []queryResult
+	for i, w := range pd.words {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if re.MatchString(w) {
+			result = append(result, pd.queryResult(i))
+		}
+	}
+	return result
+}
+
+func writeQueryResults(w io.Writer, results []queryResult) error {
+	var buf = bufio.NewWriter(w)
+	fmt.Fprintln(buf, "# word\tcount\trank\tper million\tcoverage (%)")
+	for _, r := range results {
+		if r.rank == 0 {
+			fmt.Fprintf(buf, "%s\t0\t-\t0\t-\n", r.word)
+			continue
+		}
+		fmt.Fprintf(buf, "%s\t%d\t%d\t%.2f\t%.2f\n", r.word, r.count, r.rank, r.perMillion, r.coverage)
+	}
+	return buf.Flush()
+}
+
+func queryCmd(args []string) {
+	var usage = `
+wstats query looks up words in a word frequency list (tsv output of wstats), and prints the count, rank
+(words with the same count have the same rank), frequency per million words, and the coverage of the list
+up to the rank of the word. The words are read from the command line, or from standard in (one per line).
+
+Usage:
+ $ go run . query <flags> <word frequency list> <words>
+
+Cmd line flags:
+  -re string  regexp: list the words matching a regular expression, in frequency order (optional)
+  -n int      max no. of words listed for -re (optional, default = 100, 0 = all)
+  -case       case sensitive lookup: don't lower case the words (optional)
+  -h(elp)     help: print help message
+
+Example usage:
+  $ go run . query svwiki.freq och att stockholm
+  $ go run . query -re '^över' -n 20 svwiki.freq
+
+`
+	var f = flag.NewFlagSet("query", flag.ExitOnError)
+	var reString = f.String("re", "", "regexp")
+	var limit = f.Int("n", 100, "max no. of words")
+	var caseSensitive = f.Bool("case", false, "case sensitive lookup")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage)
+	}
+	if err := f.Parse(args); err != nil || len(f.Args()) < 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var path = f.Args()[0]
+	r, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pd, err := readFreqList(r)
+	r.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var results []queryResult
+	if *reString != "" {
+		re, err := regexp.Compile(*reString)
+		if err != nil {
+			log.Fatal("Invalid regexp: ", err)
+		}
+		results = pd.queryRegexp(re, *limit)
+	} else {
+		var words = f.Args()[1:]
+		if len(words) == 0 {
+			var scanner = bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				if w := strings.TrimSpace(scanner.Text()); w != "" {
+					words = append(words, w)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if !*caseSensitive {
+			// the words of the frequency lists are lower case (unless counted with -case)
+			for i, w := range words {
+				words[i] = strings.ToLower(w)
+			}
+		}
+		results = pd.query(words)
+	}
+	if err := writeQueryResults(os.Stdout, results); err != nil {
+		log.Fatal(err)
+	}
+}
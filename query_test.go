@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	pd, err := readFreqList(strings.NewReader("5\toch\n3\ti\n1\tatt\n1\tär\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeQueryResults(&buf, pd.query([]string{"är", "i", "xyz"})); err != nil {
+		t.Fatal(err)
+	}
+	var expect = "# word\tcount\trank\tper million\tcoverage (%)\n" +
+		"är\t1\t3\t100000.00\t100.00\n" +
+		"i\t3\t2\t300000.00\t80.00\n" +
+		"xyz\t0\t-\t0\t-\n"
+	if buf.String() != expect {
+		t.Errorf(fsExp, expect, buf.String())
+	}
+
+	var tests = []struct {
+		re     string
+		limit  int
+		expect []string
+	}{
+		{"^ä|^a", 0, []string{"att", "är"}},
+		{"^ä|^a", 1, []string{"att"}},
+		{"^[oi]", 0, []string{"och", "i"}},
+		{"x", 0, nil},
+	}
+	for _, test := range tests {
+		var result []string
+		for _, r := range pd.queryRegexp(regexp.MustCompile(test.re), test.limit) {
+			result = append(result, r.word)
+		}
+		if strings.Join(result, " ") != strings.Join(test.expect, " ") {
+			t.Errorf(fsExp, test.expect, result)
+		}
+	}
+}
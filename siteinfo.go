@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SiteInfo is used for xml parsing of the <siteinfo> header of a dump file
+type SiteInfo struct {
+	SiteName   string      `xml:"sitename"`
+	DBName     string      `xml:"dbname"`
+	Base       string      `xml:"base"`
+	Generator  string      `xml:"generator"`
+	Case       string      `xml:"case"`
+	Namespaces []Namespace `xml:"namespaces>namespace"`
+	// Aliases are additional namespace names, not included in the dump file (see readNamespaceAliases)
+	Aliases []Namespace `xml:"-"`
+	// ImageOptions are additional names of the image options of file links, not included in the dump file (see
+	// readMagicWords)
+	ImageOptions []string `xml:"-"`
+}
+
+// Namespace is used for xml parsing of <siteinfo><namespaces>
+type Namespace struct {
+	Key  int    `xml:"key,attr"`
+	Case string `xml:"case,attr"`
+	Name string `xml:",chardata"`
+}
+
+const (
+	mediaNamespace    = -2
+	mainNamespace     = 0
+	userNamespace     = 2
+	userTalkNamespace = 3
+	fileNamespace     = 6
+	categoryNamespace = 14
+)
+
+// canonicalNamespaces are the english namespace names, that are valid on all wikis
+var canonicalNamespaces = map[int][]string{
+	-2:  {"Media"},
+	-1:  {"Special"},
+	1:   {"Talk"},
+	2:   {"User"},
+	3:   {"User talk"},
+	4:   {"Project"},
+	5:   {"Project talk"},
+	6:   {"File", "Image"},
+	7:   {"File talk", "Image talk"},
+	8:   {"MediaWiki"},
+	9:   {"MediaWiki talk"},
+	10:  {"Template"},
+	11:  {"Template talk"},
+	12:  {"Help"},
+	13:  {"Help talk"},
+	14:  {"Category"},
+	15:  {"Category talk"},
+	828: {"Module"},
+	829: {"Module talk"},
+}
+
+// canonicalImageOptions are the english names of the image options of file links (magic words), that are valid on
+// all wikis ($1 is a parameter)
+var canonicalImageOptions = []string{
+	"$1px", "$1x$1px", "x$1px", "upright", "upright=$1", "thumb", "thumbnail", "thumbnail=$1", "frame", "framed",
+	"frameless", "border", "left", "right", "center", "centre", "none", "baseline", "middle", "sub", "super", "top",
+	"text-top", "bottom", "text-bottom", "alt=$1", "link=$1", "page=$1", "class=$1", "lang=$1",
+}
+
+// localImageOptions are the localised names of the image options of some languages, used with the canonical names
+// for wikis of that language (by dbname). For other languages, they can be read with -magicwords.
+var localImageOptions = map[string][]string{
+	"sv": {"miniatyr", "miniatyrbild", "mini", "ram", "ramlös", "kantlinje", "vänster", "höger", "centrerad", "ingen"},
+	"no": {"miniatyr", "mini", "ramme", "rammeløs", "kantlinje", "venstre", "høyre", "sentrert", "ingen"},
+	"fi": {"pienoiskuva", "pienois", "kehys", "kehyksetön", "reunus", "vasen", "oikea", "keskitetty", "keski", "tyhjä", "pysty"},
+	"de": {"mini", "miniatur", "rahmen", "gerahmt", "rahmenlos", "rand", "links", "rechts", "zentriert", "ohne", "hochkant", "hochkant=$1", "alternativtext=$1", "verweis=$1"},
+	"fr": {"vignette", "cadre", "encadré", "sans_cadre", "bordure", "gauche", "droite", "centré", "néant", "redresse", "redresse=$1", "lien=$1"},
+	"es": {"miniatura", "miniaturadeimagen", "marco", "sinmarco", "borde", "izquierda", "derecha", "centro", "ninguna", "vertical", "enlace=$1"},
+	"ru": {"мини", "миниатюра", "обрамить", "безрамки", "граница", "слева", "справа", "центр", "без", "$1пкс", "альт=$1", "ссылка=$1"},
+	"el": {"μικρογραφία", "αριστερά", "δεξιά", "κέντρο", "κανένα"},
+}
+
+// defaultSiteInfo is used for dumps without a siteinfo header (Swedish namespaces, plus the Russian category namespace)
+var defaultSiteInfo = SiteInfo{
+	Namespaces: []Namespace{
+		{Key: -2, Name: "Media"},
+		{Key: -1, Name: "Special"},
+		{Key: 1, Name: "Diskussion"},
+		{Key: 2, Name: "Användare"},
+		{Key: 3, Name: "Användardiskussion"},
+		{Key: 4, Name: "Wikipedia"},
+		{Key: 5, Name: "Wikipediadiskussion"},
+		{Key: 6, Name: "Fil"},
+		{Key: 7, Name: "Fildiskussion"},
+		{Key: 8, Name: "MediaWiki"},
+		{Key: 9, Name: "MediaWiki-diskussion"},
+		{Key: 10, Name: "Mall"},
+		{Key: 11, Name: "Malldiskussion"},
+		{Key: 12, Name: "Hjälp"},
+		{Key: 13, Name: "Hjälpdiskussion"},
+		{Key: 14, Name: "Kategori"},
+		{Key: 15, Name: "Kategoridiskussion"},
+		{Key: 100, Name: "Portal"},
+		{Key: 101, Name: "Portaldiskussion"},
+		{Key: 828, Name: "Modul"},
+		{Key: 829, Name: "Moduldiskussion"},
+	},
+	Aliases: []Namespace{
+		{Key: 14, Name: "Категория"},
+	},
+	ImageOptions: localImageOptions["sv"],
+}
+
+// namespaceNames returns all names for the namespace: the local name, the aliases and the canonical names
+func (si SiteInfo) namespaceNames(key int) []string {
+	var result []string
+	var seen = make(map[string]bool)
+	var add = func(name string) {
+		name = strings.TrimSpace(name)
+		if len(name) > 0 && !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	for _, ns := range si.Namespaces {
+		if ns.Key == key {
+			add(ns.Name)
+		}
+	}
+	for _, ns := range si.Aliases {
+		if ns.Key == key {
+			add(ns.Name)
+		}
+	}
+	for _, name := range canonicalNamespaces[key] {
+		add(name)
+	}
+	return result
+}
+
+// otherNamespaceNames returns the names of all namespaces except the main and category namespaces
+func (si SiteInfo) otherNamespaceNames() []string {
+	var keys = make(map[int]bool)
+	for _, ns := range si.Namespaces {
+		keys[ns.Key] = true
+	}
+	for _, ns := range si.Aliases {
+		keys[ns.Key] = true
+	}
+	for key := range canonicalNamespaces {
+		keys[key] = true
+	}
+	var sorted []int
+	for key := range keys {
+		if key != mainNamespace && key != categoryNamespace {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Ints(sorted)
+	var result []string
+	for _, key := range sorted {
+		result = append(result, si.namespaceNames(key)...)
+	}
+	return result
+}
+
+// imageOptionNames returns the names of the image options: the canonical names, the local names of the language
+// of the wiki, and the additional names
+func (si SiteInfo) imageOptionNames() []string {
+	var result = append([]string{}, canonicalImageOptions...)
+	result = append(result, localImageOptions[dbNameLang(si.DBName)]...)
+	return append(result, si.ImageOptions...)
+}
+
+// imageOptionPattern returns a case insensitive regexp matching a whole image option, with any parameter values
+func imageOptionPattern(names []string) string {
+	var alts []string
+	for _, name := range names {
+		alt := strings.Replace(regexp.QuoteMeta(name), " ", "[ _]", -1)
+		alt = strings.Replace(alt, "=\\$1", " *=.*", -1)
+		alt = strings.Replace(alt, "\\$1", "[0-9]*", -1)
+		alts = append(alts, alt)
+	}
+	return "^(?i:" + strings.Join(alts, "|") + ")$"
+}
+
+// namespaceKeys returns a map from lower case namespace names (including aliases and canonical names) to namespace keys
+func (si SiteInfo) namespaceKeys() map[string]int {
+	var keys = make(map[int]bool)
+	for _, ns := range si.Namespaces {
+		keys[ns.Key] = true
+	}
+	for _, ns := range si.Aliases {
+		keys[ns.Key] = true
+	}
+	for key := range canonicalNamespaces {
+		keys[key] = true
+	}
+	var result = make(map[string]int)
+	for key := range keys {
+		for _, name := range si.namespaceNames(key) {
+			result[strings.ToLower(name)] = key
+		}
+	}
+	return result
+}
+
+// namespacePattern returns a case insensitive regexp matching any of the namespace names
+func namespacePattern(names []string) string {
+	var sorted = append([]string{}, names...)
+	// longest first, so that "Användardiskussion" is preferred over "Användare"
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	var alts []string
+	for _, name := range sorted {
+		alts = append(alts, strings.Replace(regexp.QuoteMeta(name), " ", "[ _]", -1))
+	}
+	return "(?i:" + strings.Join(alts, "|") + ")"
+}
+
+// readNamespaceAliases reads namespace aliases from a file or url in the json format of the MediaWiki api, e.g.
+// https://sv.wikipedia.org/w/api.php?action=query&meta=siteinfo&siprop=namespacealiases&format=json
+func readNamespaceAliases(path string) ([]Namespace, error) {
+	input, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	var response struct {
+		Query struct {
+			NamespaceAliases []struct {
+				ID    int    `json:"id"`
+				Alias string `json:"alias"`
+			} `json:"namespacealiases"`
+		} `json:"query"`
+	}
+	if err := json.NewDecoder(input).Decode(&response); err != nil {
+		return nil, err
+	}
+	var result []Namespace
+	for _, a := range response.Query.NamespaceAliases {
+		result = append(result, Namespace{Key: a.ID, Name: a.Alias})
+	}
+	return result, nil
+}
+
+// readMagicWords reads the names of the image options from a file or url with magic words, in the json format of the
+// MediaWiki api, e.g. https://ru.wikipedia.org/w/api.php?action=query&meta=siteinfo&siprop=magicwords&format=json
+func readMagicWords(path string) ([]string, error) {
+	input, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	var response struct {
+		Query struct {
+			MagicWords []struct {
+				Name    string   `json:"name"`
+				Aliases []string `json:"aliases"`
+			} `json:"magicwords"`
+		} `json:"query"`
+	}
+	if err := json.NewDecoder(input).Decode(&response); err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, mw := range response.Query.MagicWords {
+		if strings.HasPrefix(mw.Name, "img_") {
+			result = append(result, mw.Aliases...)
+		}
+	}
+	return result, nil
+}
+
+// parseNamespaces parses a comma separated list of namespace keys, or "all" (returns nil)
+func parseNamespaces(s string) (map[int]bool, error) {
+	if strings.TrimSpace(s) == "all" {
+		return nil, nil
+	}
+	var result = make(map[int]bool)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		key, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace: %s", v)
+		}
+		result[key] = true
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no namespaces in: %s", s)
+	}
+	return result, nil
+}
+
+// namespaceLabel returns the local name of the namespace, for printing
+func (si SiteInfo) namespaceLabel(key int) string {
+	if key == mainNamespace {
+		return "(main)"
+	}
+	if names := si.namespaceNames(key); len(names) > 0 {
+		return names[0]
+	}
+	return "(unknown)"
+}
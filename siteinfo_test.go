@@ -0,0 +1,186 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var elSiteInfo = SiteInfo{
+	DBName: "elwiki",
+	Namespaces: []Namespace{
+		{Key: 1, Name: "Συζήτηση"},
+		{Key: 2, Name: "Χρήστης"},
+		{Key: 3, Name: "Συζήτηση χρήστη"},
+		{Key: 6, Name: "Αρχείο"},
+		{Key: 10, Name: "Πρότυπο"},
+		{Key: 14, Name: "Κατηγορία"},
+	},
+	Aliases: []Namespace{
+		{Key: 6, Name: "Εικόνα"},
+	},
+}
+
+func TestSiteInfoTokenizer(t *testing.T) {
+	tk := newTokenizer(elSiteInfo, tokenizerOptions{parser: parserLines})
+	tests := map[string]string{
+		"[[Κατηγορία:Αρχαία ελληνική φιλοσοφία]]":                      "αρχαία ελληνική φιλοσοφία",
+		"[[Αρχείο:Parthenon.jpg|μικρογραφία|Ο Παρθενώνας]] στην Αθήνα": "ο παρθενώνας στην αθήνα",
+		"[[Εικόνα:Parthenon.jpg|μικρογραφία|Ο Παρθενώνας]]":            "ο παρθενώνας",
+		"[[File:Parthenon.jpg|thumb|Ο Παρθενώνας]]":                    "ο παρθενώνας",
+		"[[Category:Φιλοσοφία]]":                                       "φιλοσοφία",
+		"Η [[Αθήνα|πρωτεύουσα]] της Ελλάδας":                           "η πρωτεύουσα της ελλάδας",
+	}
+	for input, expect := range tests {
+		result := tk.convert(tk.preFilterLine(input))
+		if result != expect {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+
+	skipTests := map[string]bool{
+		"Υπογραφή [[Χρήστης:Παράδειγμα|Παράδειγμα]]":             true,
+		"Απάντηση [[Συζήτηση χρήστη:Παράδειγμα|συζήτηση]]":       true,
+		"Απάντηση [[Συζήτηση_χρήστη:Παράδειγμα|συζήτηση]]":       true,
+		"Απάντηση [[user talk:Παράδειγμα|συζήτηση]]":             true,
+		"Ο [[Χρήστος Παπαδόπουλος]] είναι ένας Έλληνας ηθοποιός": false,
+	}
+	for input, expect := range skipTests {
+		result := tk.skip(tk.preFilterLine(input))
+		if result != expect {
+			t.Errorf(fsExp+" for '%s'", expect, result, input)
+		}
+	}
+}
+
+func TestReadNamespaceAliases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "aliases.json")
+	json := `{"batchcomplete":"","query":{"namespacealiases":[{"id":6,"alias":"Bild"},{"id":2,"alias":"Användarinna"}]}}`
+	if err := ioutil.WriteFile(path, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+	aliases, err := readNamespaceAliases(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Namespace{{Key: 6, Name: "Bild"}, {Key: 2, Name: "Användarinna"}}
+	if len(aliases) != len(expect) || aliases[0] != expect[0] || aliases[1] != expect[1] {
+		t.Errorf(fsExp, expect, aliases)
+	}
+
+	var opts = loadOptions{pageLimit: -1, logAt: 100, nsAliases: aliases}
+	result := loadXML(testXML, opts)
+	if result.siteInfo.DBName != "svwiki" {
+		t.Errorf(fsExp, "svwiki", result.siteInfo.DBName)
+	}
+	if len(result.siteInfo.Namespaces) != 22 {
+		t.Errorf(fsExp, 22, len(result.siteInfo.Namespaces))
+	}
+	tk := result.tk
+	if result := tk.convert("[[Bild:Test.jpg|miniatyr|Bildtext]]"); result != "bildtext" {
+		t.Errorf(fsExp, "bildtext", result)
+	}
+	if !tk.skip("[[Användarinna:Test|Test]]") {
+		t.Errorf("expected line with user link to be skipped")
+	}
+}
+
+func TestNamespaceFilter(t *testing.T) {
+	namespaces, err := parseNamespaces("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opts = loadOptions{pageLimit: -1, logAt: 100, namespaces: namespaces}
+	result := loadXML(testXML, opts)
+	if result.nPages != 5 {
+		t.Errorf(fsExp, 5, result.nPages)
+	}
+	if result.nRedirects != 1 {
+		t.Errorf(fsExp, 1, result.nRedirects)
+	}
+	expect := map[int]int{0: 5, 1: 1, 10: 1, 14: 1}
+	if !reflect.DeepEqual(result.nsPages, expect) {
+		t.Errorf(fsExp, expect, result.nsPages)
+	}
+	if result.wordFreqs["mall"] != 0 {
+		t.Errorf(fsExp, 0, result.wordFreqs["mall"])
+	}
+
+	opts.namespaces, _ = parseNamespaces("1, 14")
+	result = loadXML(testXML, opts)
+	if result.nPages != 2 {
+		t.Errorf(fsExp, 2, result.nPages)
+	}
+
+	opts.namespaces, _ = parseNamespaces("all")
+	result = loadXML(testXML, opts)
+	if result.nPages != 8 {
+		t.Errorf(fsExp, 8, result.nPages)
+	}
+
+	if _, err := parseNamespaces("0,main"); err == nil {
+		t.Errorf("expected error for invalid namespace list")
+	}
+}
+
+func TestImageOptions(t *testing.T) {
+	var ruSiteInfo = SiteInfo{
+		DBName:     "ruwiki",
+		Namespaces: []Namespace{{Key: 6, Name: "Файл"}, {Key: 14, Name: "Категория"}},
+	}
+	tests := map[string]string{
+		"[[Файл:Kremlin.jpg|мини|справа|250пкс|Московский Кремль]] Текст": "Московский Кремль Текст",
+		"[[Файл:Kremlin.jpg|мини|справа]] Текст":                          "Текст",
+		"[[File:Kremlin.jpg|thumb|upright=1.2|альт=Кремль]] Текст":        "Текст",
+		"[[Файл:Kremlin.jpg|thumb|ссылка = Кремль|Справа]] Текст":         "Текст",
+	}
+	tk := newTokenizer(ruSiteInfo, tokenizerOptions{})
+	for input, expect := range tests {
+		if result := tk.parseWikitext(input); result != expect {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+
+	// the Swedish names are not used on other wikis
+	var enSiteInfo = SiteInfo{DBName: "enwiki", Namespaces: []Namespace{{Key: 6, Name: "File"}}}
+	tk = newTokenizer(enSiteInfo, tokenizerOptions{})
+	if result, expect := tk.parseWikitext("[[File:Map.png|thumb|left|Höger]]"), "Höger"; result != expect {
+		t.Errorf(fsExp, expect, result)
+	}
+}
+
+func TestReadMagicWords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "magicwords.json")
+	json := `{"batchcomplete":"","query":{"magicwords":[{"name":"img_thumbnail","aliases":["μικρ","thumb"],"case-sensitive":""},` +
+		`{"name":"img_width","aliases":["$1εσ","$1px"],"case-sensitive":""},{"name":"toc","aliases":["__TOC__"],"case-sensitive":""}]}}`
+	if err := ioutil.WriteFile(path, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+	imageOptions, err := readMagicWords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"μικρ", "thumb", "$1εσ", "$1px"}
+	if !reflect.DeepEqual(imageOptions, expect) {
+		t.Errorf(fsExp, expect, imageOptions)
+	}
+
+	var si = elSiteInfo
+	si.ImageOptions = imageOptions
+	tk := newTokenizer(si, tokenizerOptions{})
+	if result, expect := tk.parseWikitext("[[Αρχείο:Parthenon.jpg|μικρ|300εσ]] Ο Παρθενώνας"), "Ο Παρθενώνας"; result != expect {
+		t.Errorf(fsExp, expect, result)
+	}
+}
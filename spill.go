@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Memory bounded counting: when the estimated memory use of the word and n-gram counts exceeds the budget (see -maxmem),
+// the counts are sorted and written to temporary run files, and the in-memory counts are cleared. At the end, the runs
+// are merged (k-way merge), and the frequency list is sorted by frequency using an external sort, so that the output
+// is the same as for the in-memory counts.
+
+// mapEntrySize is the estimated memory use of a map entry, in addition to the key
+const mapEntrySize = 64
+
+const (
+	spillWords  = "words"
+	spillNgrams = "ngrams"
+)
+
+type spiller struct {
+	dir    string // directory for the run files
+	budget int    // memory budget in bytes
+	size   int    // estimated memory use of the in-memory counts
+	runs   map[string][]string
+}
+
+func newSpiller(dir string, budget int) *spiller {
+	return &spiller{dir: dir, budget: budget, runs: make(map[string][]string)}
+}
+
+// add adds the count to the map, keeping track of the memory use
+func (sp *spiller) add(m map[string]int, key string, f int) {
+	old, ok := m[key]
+	if !ok {
+		sp.size += len(key) + mapEntrySize
+	}
+	m[key] = old + f
+}
+
+// addSize adds the estimated memory use of counts that were not added with add (restored from a checkpoint)
+func (sp *spiller) addSize(maps ...map[string]int) {
+	for _, m := range maps {
+		for key := range m {
+			sp.size += len(key) + mapEntrySize
+		}
+	}
+}
+
+// spillIfDue writes the counts to run files, if the memory budget is exceeded
+func (sp *spiller) spillIfDue(result *loadResult) {
+	if sp == nil || sp.size <= sp.budget {
+		return
+	}
+	if err := sp.spill(spillWords, result.wordFreqs); err != nil {
+		log.Fatal("Couldn't write counts to disk: ", err)
+	}
+	if err := sp.spill(spillNgrams, result.ngramFreqs); err != nil {
+		log.Fatal("Couldn't write counts to disk: ", err)
+	}
+	result.wordFreqs = make(map[string]int)
+	result.ngramFreqs = make(map[string]int)
+	sp.size = 0
+}
+
+// spill writes the counts to a run file, sorted by key
+func (sp *spiller) spill(name string, m map[string]int) error {
+	if len(m) == 0 {
+		return nil
+	}
+	var keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	path, err := sp.writeRun(name, len(keys), func(i int) (string, int) { return keys[i], m[keys[i]] })
+	if err != nil {
+		return err
+	}
+	sp.runs[name] = append(sp.runs[name], path)
+	return nil
+}
+
+// writeRun writes n counts to a new run file, one per line, as count<tab>key
+func (sp *spiller) writeRun(name string, n int, get func(i int) (string, int)) (string, error) {
+	file, err := ioutil.TempFile(sp.dir, "wstats-"+name+"-*.run")
+	if err != nil {
+		return "", err
+	}
+	var w = bufio.NewWriter(file)
+	for i := 0; i < n; i++ {
+		k, v := get(i)
+		fmt.Fprintf(w, "%d\t%s\n", v, k)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// start: k-way merge
+
+type runReader struct {
+	scanner *bufio.Scanner
+	closer  io.Closer
+	current freq
+}
+
+func openRun(path string) (*runReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var scanner = bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &runReader{scanner: scanner, closer: file}, nil
+}
+
+// next reads the next count of the run, or returns false at the end of the run
+func (r *runReader) next() (bool, error) {
+	if !r.scanner.Scan() {
+		return false, r.scanner.Err()
+	}
+	fs := strings.SplitN(r.scanner.Text(), "\t", 2)
+	if len(fs) != 2 {
+		return false, fmt.Errorf("invalid line in run file: %s", r.scanner.Text())
+	}
+	v, err := strconv.Atoi(fs[0])
+	if err != nil {
+		return false, err
+	}
+	r.current = freq{fs[1], v}
+	return true, nil
+}
+
+// runHeap orders the runs by their current count, using less
+type runHeap struct {
+	runs []*runReader
+	less func(a, b freq) bool
+}
+
+func (h *runHeap) Len() int           { return len(h.runs) }
+func (h *runHeap) Less(i, j int) bool { return h.less(h.runs[i].current, h.runs[j].current) }
+func (h *runHeap) Swap(i, j int)      { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *runHeap) Push(x interface{}) { h.runs = append(h.runs, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	r := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return r
+}
+
+func byKey(a, b freq) bool { return a.Key < b.Key }
+
+// byCountDesc is the order of sortByWordCount
+func byCountDesc(a, b freq) bool { return a.Value > b.Value || (a.Value == b.Value && a.Key < b.Key) }
+
+// push reads the next count of the run, and adds the run to the heap (or closes it at the end of the run)
+func (h *runHeap) push(r *runReader) error {
+	ok, err := r.next()
+	if err != nil {
+		return err
+	}
+	if ok {
+		heap.Push(h, r)
+	} else {
+		r.closer.Close()
+	}
+	return nil
+}
+
+// mergeRuns merges the run files, in the order given by less, and calls emit for each count
+func mergeRuns(paths []string, less func(a, b freq) bool, emit func(f freq) error) error {
+	var h = &runHeap{less: less}
+	defer func() {
+		for _, r := range h.runs {
+			r.closer.Close()
+		}
+	}()
+	for _, path := range paths {
+		r, err := openRun(path)
+		if err != nil {
+			return err
+		}
+		if err := h.push(r); err != nil {
+			return err
+		}
+	}
+	for h.Len() > 0 {
+		r := heap.Pop(h).(*runReader)
+		if err := emit(r.current); err != nil {
+			return err
+		}
+		if err := h.push(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// end: k-way merge
+
+// writeFreqList writes the frequency list, sorted by frequency, for the in-memory counts and any runs written
+// to disk (limited by min freq). It returns the number of unique keys.
+func (sp *spiller) writeFreqList(lw listWriter, name string, m map[string]int, minFreq int) (int, error) {
+	if sp == nil || len(sp.runs[name]) == 0 {
+		for _, pair := range sortByWordCount(m) {
+			if pair.Value >= minFreq {
+				if err := lw.writeRow(pair.Value, pair.Key); err != nil {
+					return 0, err
+				}
+			}
+		}
+		return len(m), nil
+	}
+	if err := sp.spill(name, m); err != nil {
+		return 0, err
+	}
+	var runs = sp.runs[name]
+	defer removeFiles(runs)
+
+	// sum the counts by key, and write runs sorted by frequency
+	var sorted []string
+	defer func() { removeFiles(sorted) }()
+	var buf freqList
+	var bufSize = 0
+	var flush = func() error {
+		sort.Sort(sort.Reverse(buf))
+		path, err := sp.writeRun(name+"-sorted", len(buf), func(i int) (string, int) { return buf[i].Key, buf[i].Value })
+		if err != nil {
+			return err
+		}
+		sorted = append(sorted, path)
+		buf = buf[:0]
+		bufSize = 0
+		return nil
+	}
+	var nUnique = 0
+	var current = freq{Value: -1}
+	var add = func() error {
+		nUnique++
+		if current.Value < minFreq {
+			return nil
+		}
+		buf = append(buf, current)
+		bufSize += len(current.Key) + mapEntrySize
+		if bufSize > sp.budget {
+			return flush()
+		}
+		return nil
+	}
+	err := mergeRuns(runs, byKey, func(f freq) error {
+		if current.Value >= 0 && f.Key == current.Key {
+			current.Value += f.Value
+			return nil
+		}
+		if current.Value >= 0 {
+			if err := add(); err != nil {
+				return err
+			}
+		}
+		current = f
+		return nil
+	})
+	if err == nil && current.Value >= 0 {
+		err = add()
+	}
+	if err == nil && len(buf) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+	err = mergeRuns(sorted, byCountDesc, func(f freq) error {
+		return lw.writeRow(f.Value, f.Key)
+	})
+	delete(sp.runs, name)
+	return nUnique, err
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
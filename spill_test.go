@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, minFreq := range []int{0, 2} {
+		var opts = loadOptions{pageLimit: -1, logAt: 100, ngramN: 2}
+		expect := loadXML(testXML, opts)
+
+		opts.memBudget = 1000
+		opts.spillDir = dir
+		result := loadXML(testXML, opts)
+		if len(result.spill.runs[spillWords]) < 2 {
+			t.Errorf("expected more than one run, got %d", len(result.spill.runs[spillWords]))
+		}
+
+		for _, name := range []string{spillWords, spillNgrams} {
+			var expectFreqs, resultFreqs = expect.wordFreqs, result.wordFreqs
+			if name == spillNgrams {
+				expectFreqs, resultFreqs = expect.ngramFreqs, result.ngramFreqs
+			}
+			var expectOut, resultOut bytes.Buffer
+			var expectW = &tsvWriter{bufio.NewWriter(&expectOut), nopCloser{&expectOut}}
+			var resultW = &tsvWriter{bufio.NewWriter(&resultOut), nopCloser{&resultOut}}
+			n1, err := expect.spill.writeFreqList(expectW, name, expectFreqs, minFreq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// a small budget, so that the frequency sorted list is also split into several runs
+			result.spill.budget = 200
+			n2, err := result.spill.writeFreqList(resultW, name, resultFreqs, minFreq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectW.close()
+			resultW.close()
+			if n1 != n2 {
+				t.Errorf(fsExp, n1, n2)
+			}
+			if expectOut.String() != resultOut.String() {
+				t.Errorf(fsExp, expectOut.String(), resultOut.String())
+			}
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected temporary files to be removed, found %d", len(files))
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Word segmentation at Unicode word boundaries (UAX #29, https://unicode.org/reports/tr29/), as an alternative to
+// the punctuation regexps of the default tokenizer. The text is NFC normalised first, so that words written with
+// combining characters are counted together with their precomposed forms. Apostrophes and colons between letters
+// (don’t, EU:n) are kept within words, any kind of space or punctuation separates words, and scripts without spaces
+// are split into single characters (there is no dictionary based segmentation).
+
+const (
+	tokenizerRegexp = "regexp"
+	tokenizerUAX29  = "uax29"
+)
+
+// isWord is true if the segment has a letter or digit (segments of spaces and punctuation are not words)
+func isWord(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentWords splits the text into words at Unicode word boundaries
+func segmentWords(s string) []string {
+	var result []string
+	var word string
+	var state = -1
+	s = norm.NFC.String(s)
+	for len(s) > 0 {
+		word, s, state = uniseg.FirstWordInString(s, state)
+		if isWord(word) {
+			result = append(result, word)
+		}
+	}
+	return result
+}
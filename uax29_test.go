@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSegmentWords(t *testing.T) {
+	var tests = map[string][]string{
+		"Don’t stop":                {"don’t", "stop"},
+		"EU:n och S:t Petersburg":   {"eu:n", "och", "s:t", "petersburg"},
+		"8 839 247 invånare":        {"8", "839", "247", "invånare"},
+		"10 000 kr":                 {"10", "000", "kr"},
+		"pi = 3.14, inte 3,15!":     {"pi", "3.14", "inte", "3,15"},
+		"«Привет, мир!» сказал он.": {"привет", "мир", "сказал", "он"},
+		"Τι κάνεις; Καλά.":          {"τι", "κάνεις", "καλά"},
+		"Café och café":            {"café", "och", "café"},
+		"東京都":                       {"東", "京", "都"},
+		"(1808–1809) -- ...":        {"1808", "1809"},
+		"l'homme, c'est":            {"l'homme", "c'est"},
+	}
+	for input, expect := range tests {
+		var result = segmentWords(strings.ToLower(input))
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(fsExp, expect, result)
+		}
+	}
+}
+
+func TestTokenizerUAX29(t *testing.T) {
+	var text = "'''Stockholm''' är [[Sverige]]s huvudstad.\nDon’t stop, 8&nbsp;839 [[EU]]:n."
+	var tests = []struct {
+		parser string
+		expect string
+	}{
+		{parserWikitext, "stockholm är sveriges huvudstad don’t stop 8 839 eu:n"},
+		{parserLines, "stockholm är sveriges huvudstad don’t stop 8839 eu:n"},
+	}
+	for _, test := range tests {
+		var tk = newTokenizer(defaultSiteInfo, tokenizerOptions{parser: test.parser, tokenizer: tokenizerUAX29})
+		_, _, sentences := tk.tokenizeSentences(text)
+		var words []string
+		for _, s := range sentences {
+			words = append(words, s...)
+		}
+		if result := strings.Join(words, " "); result != test.expect {
+			t.Errorf(fsExp, test.expect, result)
+		}
+	}
+}
+
+func TestLoadUAX29(t *testing.T) {
+	var opts = loadOptions{pageLimit: -1, logAt: 100}
+	opts.tkOpts.tokenizer = tokenizerUAX29
+	var result = loadXML(testXML, opts)
+	if result.nWords == 0 {
+		t.Errorf("expected words, found none")
+	}
+	for w := range result.wordFreqs {
+		if !isWord(w) || strings.ContainsAny(w, " \t.,;!?()") {
+			t.Errorf("unexpected word: %q", w)
+		}
+	}
+}
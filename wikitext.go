@@ -0,0 +1,401 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// A wikitext parser, converting the wikitext of a page into plain text (one paragraph per line).
+// Templates, tables, comments, references and other non-prose tags are removed. Links are replaced by their
+// label, file links by their caption, and category and interlanguage links are removed. Headings and list
+// items are kept as separate lines.
+//
+// The text is parsed in two passes: the first handles inline and multi-line markup, the second handles markup
+// that is defined per line (headings, lists, indentation, horizontal rules).
+
+// tags that are removed together with their content
+var wikitextDropTags = map[string]bool{
+	"ref":             true,
+	"references":      true,
+	"math":            true,
+	"chem":            true,
+	"gallery":         true,
+	"timeline":        true,
+	"syntaxhighlight": true,
+	"source":          true,
+	"pre":             true,
+	"score":           true,
+	"graph":           true,
+	"hiero":           true,
+	"imagemap":        true,
+	"mapframe":        true,
+	"maplink":         true,
+	"templatedata":    true,
+	"templatestyles":  true,
+	"inputbox":        true,
+	"categorytree":    true,
+	"style":           true,
+	"script":          true,
+}
+
+var wikitextTagRe = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)([^<>]*?)(/?)>`)
+var wikitextURLRe = regexp.MustCompile(`^(?i)(https?://|ftp://|//|mailto:)`)
+var wikitextMagicWordRe = regexp.MustCompile(`^__[A-ZÅÄÖ]+__`)
+var wikitextEscapedTagRe = regexp.MustCompile(`&lt;(/?[a-zA-Z][a-zA-Z0-9]*[^<>]*?/?)&gt;`)
+var wikitextEscapedCommentReplacer = strings.NewReplacer("&lt;!--", "<!--", "--&gt;", "-->")
+var wikitextEntityRe = regexp.MustCompile(`^&(#[0-9]+|#x[0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+var wikitextHeadingRe = regexp.MustCompile(`^(=+)\s*(.*?)\s*(=+)\s*$`)
+var wikitextListRe = regexp.MustCompile(`^[*#:;]+\s*`)
+var wikitextRuleRe = regexp.MustCompile(`^----+\s*`)
+var wikitextLanguageLinkRe = regexp.MustCompile(`^[a-z]{2,3}(-[a-z]+)*$`)
+
+// parseWikitext converts wikitext into plain text
+func (tk *tokenizer) parseWikitext(text string) string {
+	// tags and comments that are html escaped in the wikitext (&lt;ref&gt;, as in some dumps) are parsed as markup,
+	// not decoded into text after the tags have been removed
+	text = wikitextEscapedCommentReplacer.Replace(wikitextEscapedTagRe.ReplaceAllString(text, "<$1>"))
+	var lines []string
+	for _, l := range strings.Split(tk.parseInline(text), "\n") {
+		l = strings.TrimSpace(l)
+		if m := wikitextHeadingRe.FindStringSubmatch(l); m != nil {
+			l = m[2]
+		}
+		l = wikitextRuleRe.ReplaceAllString(l, "")
+		l = wikitextListRe.ReplaceAllString(l, "")
+		if len(l) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseInline handles the inline and multi-line markup of the text (first pass)
+func (tk *tokenizer) parseInline(s string) string {
+	var out strings.Builder
+	var templates = bracketMatcher{s: s, open: "{{", close: "}}"}
+	var links = bracketMatcher{s: s, open: "[[", close: "]]"}
+	var i = 0
+	for i < len(s) {
+		var rest = s[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			i = skipTo(s, i+4, "-->")
+
+		case hasPrefixFold(rest, "<nowiki>"):
+			end := indexFold(s, i, "</nowiki>")
+			if end < 0 {
+				end = len(s)
+			}
+			out.WriteString(html.UnescapeString(s[i+len("<nowiki>") : end]))
+			i = skipTo(s, end, "</nowiki>")
+
+		case strings.HasPrefix(rest, "<"):
+			m := wikitextTagRe.FindStringSubmatch(rest)
+			if m == nil {
+				out.WriteByte('<')
+				i++
+				break
+			}
+			name := strings.ToLower(m[2])
+			i += len(m[0])
+			if name == "br" {
+				out.WriteString("\n")
+			} else if wikitextDropTags[name] && m[1] == "" && m[4] == "" {
+				i = skipElement(s, i, name)
+			}
+
+		case strings.HasPrefix(rest, "{{"):
+			end := templates.match(i)
+			if end < 0 {
+				i += 2
+			} else {
+				i = end
+			}
+
+		case strings.HasPrefix(rest, "{|") && isLineStart(s, i):
+			i = skipTable(s, i)
+
+		case strings.HasPrefix(rest, "[["):
+			end := links.match(i)
+			if end < 0 {
+				i += 2
+			} else {
+				out.WriteString(tk.parseLink(s[i+2 : end-2]))
+				i = end
+			}
+
+		case strings.HasPrefix(rest, "[") && wikitextURLRe.MatchString(rest[1:]):
+			end := strings.IndexAny(rest, "]\n")
+			if end < 0 || rest[end] == '\n' {
+				i = skipURL(s, i+1)
+				break
+			}
+			if sp := strings.IndexAny(rest[:end], " \t"); sp > 0 {
+				out.WriteString(tk.parseInline(rest[sp+1 : end]))
+			}
+			i += end + 1
+
+		case wikitextURLRe.MatchString(rest) && !strings.HasPrefix(rest, "//"):
+			i = skipURL(s, i)
+
+		case strings.HasPrefix(rest, "''"):
+			for i < len(s) && s[i] == '\'' {
+				i++
+			}
+
+		case strings.HasPrefix(rest, "~~~"):
+			for i < len(s) && s[i] == '~' {
+				i++
+			}
+
+		case strings.HasPrefix(rest, "__") && wikitextMagicWordRe.MatchString(rest):
+			i += len(wikitextMagicWordRe.FindString(rest))
+
+		case strings.HasPrefix(rest, "&"):
+			m := wikitextEntityRe.FindString(rest)
+			if m == "" {
+				out.WriteByte('&')
+				i++
+				break
+			}
+			out.WriteString(strings.Replace(html.UnescapeString(m), "\u00a0", " ", -1))
+			i += len(m)
+
+		default:
+			out.WriteByte(s[i])
+			i++
+		}
+	}
+	return out.String()
+}
+
+// parseLink returns the text of a link, given the link content (without the enclosing brackets)
+func (tk *tokenizer) parseLink(link string) string {
+	var params = splitTopLevel(link, '|')
+	var target = strings.TrimSpace(params[0])
+	var colonPrefixed = strings.HasPrefix(target, ":")
+	target = strings.TrimPrefix(target, ":")
+
+	var ns = mainNamespace
+	if c := strings.Index(target, ":"); c > 0 {
+		prefix := strings.TrimSpace(target[:c])
+		if key, ok := tk.namespaceKeys[strings.ToLower(strings.Replace(prefix, "_", " ", -1))]; ok {
+			ns = key
+		} else if !colonPrefixed && wikitextLanguageLinkRe.MatchString(prefix) {
+			return "" // interlanguage link
+		}
+	}
+	if !colonPrefixed {
+		switch ns {
+		case categoryNamespace:
+			return ""
+		case fileNamespace, mediaNamespace:
+			for j := len(params) - 1; j > 0; j-- {
+				p := strings.TrimSpace(params[j])
+				if !tk.imageOptionRe.MatchString(p) {
+					return tk.parseInline(p)
+				}
+			}
+			return ""
+		}
+	}
+	if len(params) > 1 {
+		return tk.parseInline(params[len(params)-1])
+	}
+	return tk.parseInline(target)
+}
+
+// start: parser utils
+
+func isLineStart(s string, i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		switch s[j] {
+		case '\n':
+			return true
+		case ' ', '\t', ':':
+			continue
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// skipTo returns the position after the next occurrence of end (case insensitive), or the end of s
+func skipTo(s string, from int, end string) int {
+	i := indexFold(s, from, end)
+	if i < 0 {
+		return len(s)
+	}
+	return i + len(end)
+}
+
+// skipElement returns the position after the closing tag of the element name, with its content starting at from.
+// If the element is not closed (before the next element of the same name), the content is skipped only to the end
+// of the paragraph, so that an unclosed tag (as an unclosed <ref>) does not remove the rest of the page.
+func skipElement(s string, from int, name string) int {
+	var end = indexFold(s, from, "</"+name+">")
+	if end >= 0 && indexTag(s[:end], from, name) < 0 {
+		return end + len("</"+name+">")
+	}
+	if i := strings.Index(s[from:], "\n\n"); i >= 0 {
+		return from + i
+	}
+	return len(s)
+}
+
+// indexTag returns the index of the first start tag of the element name in s, starting at from, or -1
+func indexTag(s string, from int, name string) int {
+	for i := indexFold(s, from, "<"+name); i >= 0; i = indexFold(s, i+1, "<"+name) {
+		if m := wikitextTagRe.FindStringSubmatch(s[i:]); m != nil && strings.EqualFold(m[2], name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// skipURL returns the position after the url starting at from
+func skipURL(s string, from int) int {
+	i := strings.IndexAny(s[from:], " \t\n<>[]{}|\"")
+	if i < 0 {
+		return len(s)
+	}
+	return from + i
+}
+
+// matchBrackets returns the position after the matching close bracket of the open bracket at from, or -1 if not found
+func matchBrackets(s string, from int, open string, close string) int {
+	var depth = 0
+	var i = from
+	for i < len(s) {
+		switch {
+		case strings.HasPrefix(s[i:], "<!--"):
+			i = skipTo(s, i+4, "-->")
+		case strings.HasPrefix(s[i:], open):
+			depth++
+			i += len(open)
+		case strings.HasPrefix(s[i:], close):
+			depth--
+			i += len(close)
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// bracketMatcher matches all open brackets of s in one pass (on the first call), so that unmatched brackets in
+// malformed or truncated pages are not rescanned to the end of the text
+type bracketMatcher struct {
+	s     string
+	open  string
+	close string
+	ends  map[int]int // the position after the matching close bracket, by position of the open bracket (-1 if none)
+}
+
+// match returns the position after the matching close bracket of the open bracket at from, or -1 if not found
+func (bm *bracketMatcher) match(from int) int {
+	if bm.ends == nil {
+		bm.ends = matchAllBrackets(bm.s, bm.open, bm.close)
+	}
+	if end, ok := bm.ends[from]; ok {
+		return end
+	}
+	// an open bracket that overlaps with another one (as in {{{), not seen by matchAllBrackets
+	return matchBrackets(bm.s, from, bm.open, bm.close)
+}
+
+// matchAllBrackets returns the position after the matching close bracket of each open bracket, by the position of
+// the open bracket (-1 if not found). The brackets are matched as by matchBrackets.
+func matchAllBrackets(s string, open string, close string) map[int]int {
+	var result = make(map[int]int)
+	var stack []int
+	var i = 0
+	for i < len(s) {
+		switch {
+		case strings.HasPrefix(s[i:], "<!--"):
+			i = skipTo(s, i+4, "-->")
+		case strings.HasPrefix(s[i:], open):
+			stack = append(stack, i)
+			i += len(open)
+		case strings.HasPrefix(s[i:], close):
+			i += len(close)
+			if len(stack) > 0 {
+				result[stack[len(stack)-1]] = i
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			i++
+		}
+	}
+	for _, from := range stack {
+		result[from] = -1
+	}
+	return result
+}
+
+// skipTable returns the position after the (possibly nested) table starting at from
+func skipTable(s string, from int) int {
+	var depth = 0
+	var i = from
+	for i < len(s) {
+		end := strings.IndexByte(s[i:], '\n')
+		if end < 0 {
+			end = len(s)
+		} else {
+			end = i + end + 1
+		}
+		line := strings.TrimLeft(s[i:end], " \t:")
+		if strings.HasPrefix(line, "{|") {
+			depth++
+		} else if strings.HasPrefix(line, "|}") {
+			depth--
+		}
+		i = end
+		if depth == 0 {
+			break
+		}
+	}
+	return i
+}
+
+// splitTopLevel splits s on sep, except inside links and templates
+func splitTopLevel(s string, sep byte) []string {
+	var result []string
+	var depth = 0
+	var start = 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "[[") || strings.HasPrefix(s[i:], "{{"):
+			depth++
+			i++
+		case (strings.HasPrefix(s[i:], "]]") || strings.HasPrefix(s[i:], "}}")) && depth > 0:
+			depth--
+			i++
+		case s[i] == sep && depth == 0:
+			result = append(result, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, s[start:])
+}
+
+// indexFold returns the index of the ascii string sub in s, starting at from (case insensitive), or -1 if not found
+func indexFold(s string, from int, sub string) int {
+	for i := from; i+len(sub) <= len(s); i++ {
+		if hasPrefixFold(s[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasPrefixFold(s string, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// end: parser utils
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWikitext(t *testing.T) {
+	tk := newTokenizer(defaultSiteInfo, tokenizerOptions{})
+
+	tests := map[string]string{
+		// links
+		"Vid [[Teherankonferensen|konferensen]] med [[Churchill]]":                            "Vid konferensen med Churchill",
+		"I [[upplysningen]]s Europa":                                                          "I upplysningens Europa",
+		"[[Fil:House sparrow04.jpg|miniatyr|vänster|[[Gråsparv]]ens utbredningsområde]] Text": "Gråsparvens utbredningsområde Text",
+		"[[Fil:Karta.png|200px]] Text":                                                        "Text",
+		"[[Kategori:Personer inom Sveriges näringsliv under 1700-talet]]":                     "",
+		"[[:Kategori:Ateism|kategorin ateism]]":                                               "kategorin ateism",
+		"[[en:Atheism]]":                                                                      "",
+		"Denna position förde Blom till [[S:t Petersburg]]":                                   "Denna position förde Blom till S:t Petersburg",
+		"[http://www.fishbase.org/search.php Fishbase], en databas":                           "Fishbase, en databas",
+		"Se http://www.example.com/ för mer":                                                  "Se  för mer",
+
+		// references: the text after the reference is kept
+		"ateism.<ref>Martin M ''Atheism''</ref> Sedan dess":             "ateism. Sedan dess",
+		"ateism.<ref name=\"a\" /> Sedan dess":                          "ateism. Sedan dess",
+		"ateism.&lt;ref&gt;Martin M ''Atheism''&lt;/ref&gt; Sedan dess": "ateism. Sedan dess",
+		"Amager Strandpark&lt;!--stort S--&gt; med":                     "Amager Strandpark med",
+		"3 &lt; 4 &gt; 2": "3 < 4 > 2",
+		"ateism.<ref name=\"b\">\n{{Webbref\n| titel = X\n}}\n</ref> Sedan dess": "ateism. Sedan dess",
+		// an unclosed reference is skipped to the end of the paragraph
+		"ateism.<ref>Martin M ''Atheism''\n\nSedan dess finns ateister.":                    "ateism.\nSedan dess finns ateister.",
+		"ateism.<ref>Martin M\n\nSedan dess.<ref>Källa</ref> Ateister finns.<references />": "ateism.\nSedan dess. Ateister finns.",
+
+		// templates, multi-line and nested
+		"{{Infobox stad\n| namn = Jakarta\n| land = {{flagga|Indonesien}}\n}}\n'''Jakarta''' är en stad": "Jakarta är en stad",
+		"Text {{citat|ej avslutad": "Text citat|ej avslutad",
+
+		// comments, nowiki, entities
+		"Amager Strandpark<!--stort S\npå danska--> med en sandstrand": "Amager Strandpark med en sandstrand",
+		"<nowiki>[[inte en länk]]</nowiki> text":                       "[[inte en länk]] text",
+		"8&nbsp;839&nbsp;247 invånare &amp; mer":                       "8 839 247 invånare & mer",
+
+		// tables
+		"Före\n{| class=\"wikitable\"\n|-\n| cell\n{|\n| nästlad\n|}\n|}\nEfter": "Före\nEfter",
+
+		// headings, lists, formatting
+		"== Historia ==\nText":                    "Historia\nText",
+		"* punkt ett\n# punkt två\n: indrag":      "punkt ett\npunkt två\nindrag",
+		"'''''fet och kursiv''''' text __NOTOC__": "fet och kursiv text",
+		"Rad ett<br />rad två":                    "Rad ett\nrad två",
+		"Ord <small>inom</small> taggar":          "Ord inom taggar",
+	}
+
+	for input, expect := range tests {
+		result := tk.parseWikitext(input)
+		if result != expect {
+			t.Errorf(fsExp+" for '%s'", expect, result, input)
+		}
+	}
+}
+
+func TestTokenizeWikitext(t *testing.T) {
+	tk := newTokenizer(defaultSiteInfo, tokenizerOptions{})
+	text := "Ateism\n'''Ateism''' är avsaknad av tro på [[gud]]ar.<ref>Källa</ref> Ateister finns i hela världen.\n{{Mall\n| x = y\n}}"
+	nLines, nLinesSkipped, wordFreqs := tk.tokenizeText(text)
+	if nLines != 5 {
+		t.Errorf(fsExp, 5, nLines)
+	}
+	if nLinesSkipped != 3 {
+		t.Errorf(fsExp, 3, nLinesSkipped)
+	}
+	expect := map[string]int{"ateism": 2, "är": 1, "avsaknad": 1, "av": 1, "tro": 1, "på": 1, "gudar": 1, "ateister": 1, "finns": 1, "i": 1, "hela": 1, "världen": 1}
+	if len(wordFreqs) != len(expect) {
+		t.Errorf(fsExp, expect, wordFreqs)
+	}
+	for w, f := range expect {
+		if wordFreqs[w] != f {
+			t.Errorf(fsExp, expect, wordFreqs)
+		}
+	}
+}
+
+func TestLoadWikitext(t *testing.T) {
+	// the word list of the articles of the test dump, with the default wikitext parser (the references are html
+	// escaped in the dump)
+	var expect = map[string]int{
+		"amager": 2, "användes": 1, "ateism": 3, "ateister": 1, "av": 1, "avsaknad": 1, "belägen": 1, "blekinge": 1,
+		"del": 1, "en": 2, "europa": 1, "finns": 2, "funnits": 1, "för": 1, "gudar": 1, "har": 1, "hela": 1,
+		"historia": 1, "huvudstaden": 1, "i": 4, "indonesien": 1, "jakarta": 2, "java": 1, "karlskrona": 2, "känd": 1,
+		"länge": 1, "marinen": 1, "med": 1, "och": 1, "ordet": 1, "populär": 1, "på": 3, "sandstrand": 1, "stad": 1,
+		"staden": 2, "stor": 1, "stranden": 1, "strandpark": 1, "svenska": 1, "tro": 1, "upplysningens": 1,
+		"världen": 1, "är": 6, "ön": 1, "öns": 1, "östra": 1,
+	}
+	var result = loadXML(testXML, loadOptions{pageLimit: -1, logAt: 100, namespaces: map[int]bool{mainNamespace: true}})
+	if !reflect.DeepEqual(result.wordFreqs, expect) {
+		t.Errorf(fsExp, expect, result.wordFreqs)
+	}
+}
+
+func TestMatchAllBrackets(t *testing.T) {
+	tests := []string{
+		"{{a}} {{b {{c}} d}} e",
+		"{{ {{a}} b",
+		"{{a <!-- }} --> b}} {{c",
+		"{{{1}}} {{{{a}}}}",
+		"}} {{a}}}} {{",
+	}
+	for _, s := range tests {
+		var bm = bracketMatcher{s: s, open: "{{", close: "}}"}
+		for i := 0; i < len(s); i++ {
+			if !strings.HasPrefix(s[i:], "{{") {
+				continue
+			}
+			if expect, result := matchBrackets(s, i, "{{", "}}"), bm.match(i); result != expect {
+				t.Errorf(fsExp+" for %d in '%s'", expect, result, i, s)
+			}
+		}
+	}
+}
+
+func TestParseWikitextUnmatched(t *testing.T) {
+	// unmatched brackets in a large (truncated) page are not rescanned to the end of the text
+	tk := newTokenizer(defaultSiteInfo, tokenizerOptions{})
+	for _, open := range []string{"{{", "[["} {
+		var text = strings.Repeat(open+"a ", 200000)
+		var start = time.Now()
+		tk.parseWikitext(text)
+		if d := time.Since(start); d > 10*time.Second {
+			t.Errorf("parsing %d unmatched %s took %v", 200000, open, d)
+		}
+	}
+}
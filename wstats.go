@@ -7,15 +7,72 @@ The program will print running progress and basic statistics to standard error.
 A complete word frequency list will be printed to standard out (limited by min freq, if set).
 
 Usage:
-	$ go run wstats.go <flags> <wikipedia dump path (file or url, xml or xml.bz2)>
+	$ go run . [count] <flags> <wikipedia dump path (file or url, xml or xml.bz2)>
+	$ go run . <command> <flags> <args>
+	$ go run . <command> -h (help for the command)
 
-Cmd line flags:
-	-pl int     page limit: limit number of pages to read (optional, default = unset)
-	-mf int     min freq: lower limit for word frequencies to be printed (optional, default = 2)
-	-h(elp)     help: print help message
+Commands:
+	count    count the words of a wikimedia dump file (the default command)
+	merge    merge word frequency lists, summing the counts (see merge.go)
+	diff     compare two word frequency lists, sorted by keyness (see diff.go)
+	plot     coverage and Zipf plot of a word frequency list (see plot.go)
+	query    look up words in a word frequency list (see query.go)
+	explain  show how wikitext is cleaned up and split into words (see explain.go)
+
+Cmd line flags (count):
+	-pl int              page limit: limit number of pages to read (optional, default = unset)
+	-mf int              min freq: lower limit for word frequencies to be printed (optional, default = 2)
+	-format string       output format: tsv, jsonl, csv (with header), sqlite or parquet (optional, default = tsv)
+	-out string          output file (optional, default = standard out, required for sqlite)
+	-index string        multistream index file (file or url), used for random access to the pages selected by -titles, -ids or -idrange (optional)
+	-titles string       file with page titles to read, one per line (optional)
+	-ids string          comma separated list of page ids to read (optional)
+	-idrange string      page id range to read, <from>-<to> (optional)
+	-workers int         number of parallel workers for multistream bz2 dumps (optional, default = 1)
+	-nsaliases string    namespace aliases (file or url), in MediaWiki api json format (optional)
+	-magicwords string   magic words (file or url), in MediaWiki api json format, for the local names of image options (optional)
+	-ns string           namespaces to count: comma separated list of namespace keys, or all (optional, default = 0)
+	-parser string       wikitext parser: wikitext (full parser) or lines (line based regexps) (optional, default = wikitext)
+	-tokenizer string    word tokenizer: regexp (punctuation regexps) or uax29 (Unicode word boundaries, with NFC normalisation) (optional, default = regexp)
+	-lang string         language profile for the tokenizer (apostrophes, hyphens, colons and abbreviations): generic, en, fi, fr or sv (optional, default = inferred from the dbname of the dump, or generic)
+	-checkpoint string   checkpoint file, saved periodically during the run (optional)
+	-cpevery int         no. of pages between checkpoints (optional, default = 100000)
+	-resume              resume from the checkpoint file, after an interrupted run (optional)
+	-retries int         no. of retries on download errors, resuming at the current position (optional, default = 5)
+	-backoff duration    wait before retrying a download, doubled for each retry (optional, default = 1s)
+	-timeout duration    download timeout: max wait for a response, or for data from an open connection, before retrying (0 for none) (optional, default = 1m0s)
+	-cache string        cache directory for downloaded files, used instead of the url on the next run (optional)
+	-checksums string    checksums file (file or url, md5sums or sha1sums), to verify the input file (optional)
+	-ngram int           n-gram size: count word n-grams within sentences, e.g. 2 for bigrams (optional)
+	-ngrammf int         min freq for n-grams to be printed (optional, default = 0)
+	-ngramfile string    output file for the n-gram frequency list (required with -ngram, except for sqlite)
+	-dispersion int      no. of corpus parts for dispersion: adds document frequency, Juilland's D and Gries' DP to the output (optional)
+	-maxmem int          memory budget in MB for the word and n-gram counts, written to temporary files when exceeded (optional, default = unset)
+	-tmpdir string       directory for temporary files (optional, default = system temp dir)
+	-counter string      word counter: exact, or spacesaving (approximate counts in fixed memory) (optional, default = exact)
+	-capacity int        no. of words (and n-grams) kept by the spacesaving counter (optional, default = 100000)
+	-manifest string     JSON manifest with the statistics, input checksum and settings of the run (optional, default = <out>.manifest.json if -out is set)
+	-revisions string    revisions to count, for pages-meta-history dumps: all or latest (optional, default = all)
+	-timebuckets string  split the counts by revision timestamp into a time series: year or month (optional)
+	-timefile string     output file for the time series (required with -timebuckets, except for sqlite)
+	-case                count surface forms: don't lower case the words (optional)
+	-casefile string     output file for the case variants of each word: total count, most frequent form, share capitalised within sentences (requires -case and the wikitext parser, optional for sqlite)
+	-sentences string    sentence splitter for the wikitext parser: rules (punctuation followed by a capital letter) or punkt (unsupervised, trained on the dump) (optional, default = rules)
+	-punktpages int      no. of pages used to train the punkt sentence splitter (optional, default = 5000)
+	-punktmodel string   punkt model file (JSON): read if it exists, or else trained on the dump and saved (optional)
+	-corpus string       gzip compressed output file for the cleaned plain text of the pages counted (optional)
+	-corpusfmt string    corpus format: jsonl (page id, revision id, title and text per line) or text (one sentence per line) (optional, default = jsonl)
+	-h(elp)              help: print help message
 
 Example usage:
-	$ go run wstats.go -pl 10000 https://dumps.wikimedia.org/svwiki/latest/svwiki-latest-pages-articles-multistream.xml.bz2
+	$ go run . -pl 10000 https://dumps.wikimedia.org/svwiki/latest/svwiki-latest-pages-articles-multistream.xml.bz2
+	$ go run . -index svwiki-latest-pages-articles-multistream-index.txt.bz2 -titles titles.txt svwiki-latest-pages-articles-multistream.xml.bz2
+	$ go run . -workers 8 svwiki-latest-pages-articles-multistream.xml.bz2
+	$ go run . merge -mf 2 -out nordic.freq svwiki.freq nowiki.freq dawiki.freq
+	$ go run . diff -mf 10 -g2 15.13 svwiki-latest.freq svwiki-20170101.freq
+	$ go run . plot svwiki.freq svwiki-coverage.svg
+	$ go run . query svwiki.freq och att stockholm
+	$ go run . explain page.wikitext
 
 
 */
@@ -26,13 +83,13 @@ package main
 // BUG(hanna) Specifically, tests are needed to detect if the xml parsing won't find any pages (known to happen when editing case for the field names)
 
 import (
-	"bufio"
 	"compress/bzip2"
+	"crypto/sha1"
 	"encoding/xml"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
 	"os"
 	"regexp"
 	"sort"
@@ -53,6 +110,15 @@ func splitWhiteSpace(s string) []string {
 	return result
 }
 
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // start: sorting
 func sortByWordCount(wordFrequencies map[string]int) freqList {
 	pl := make(freqList, len(wordFrequencies))
@@ -71,9 +137,28 @@ type freq struct {
 }
 type freqList []freq
 
-func (p freqList) Len() int           { return len(p) }
-func (p freqList) Less(i, j int) bool { return p[i].Value < p[j].Value }
-func (p freqList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p freqList) Len() int { return len(p) }
+func (p freqList) Less(i, j int) bool {
+	// words with the same frequency are sorted alphabetically (reversed, since the list is sorted in reverse)
+	return p[i].Value < p[j].Value || (p[i].Value == p[j].Value && p[i].Key > p[j].Key)
+}
+func (p freqList) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func sortedKeys(m interface{}) []int {
+	var result []int
+	switch m := m.(type) {
+	case map[int]bool:
+		for k := range m {
+			result = append(result, k)
+		}
+	case map[int]int:
+		for k := range m {
+			result = append(result, k)
+		}
+	}
+	sort.Ints(result)
+	return result
+}
 
 // end: sorting
 
@@ -87,19 +172,14 @@ type Redirect struct {
 /*
 Page is used in xml parsing.
 For implementation details, please see - http://blog.davidsingleton.org/parsing-huge-xml-files-with-go
+The revisions of a page (a single revision, except for history dumps, see history.go) are decoded one at a time
+(see decodePage).
 */
 type Page struct {
 	Title string   `xml:"title"`
+	NS    int      `xml:"ns"`
+	ID    int      `xml:"id"`
 	Redir Redirect `xml:"redirect"`
-	Text  string   `xml:"revision>text"`
-}
-
-func convert(s string) string {
-	result := s
-	for _, repl := range tokenReplacements {
-		result = repl.From.ReplaceAllString(result, repl.To)
-	}
-	return strings.ToLower(strings.TrimSpace(result))
 }
 
 // start: pre-compiled regexps
@@ -108,65 +188,141 @@ type replacement struct {
 	To   string
 }
 
-var tokenReplacements = []replacement{
-	// '''
-	{regexp.MustCompile("'''"), "\""},
-	{regexp.MustCompile("''"), "\""},
-	{regexp.MustCompile("[«»]"), "\""},
-	{regexp.MustCompile("http://[^\\s]+"), ""},
-	{regexp.MustCompile("&lt;!--"), "<!--"},
-	{regexp.MustCompile("--&gt;"), "-->"},
-	{regexp.MustCompile("<!--[^>]+-->"), ""},
-	{regexp.MustCompile("(&lt;|<)/?ref( |(&gt;|>)).*$"), ""},
-	{regexp.MustCompile("&quot;"), "\""},
-	{regexp.MustCompile("&amp;"), "&"},
-	{regexp.MustCompile("^ *\\* *"), ""},
-	{regexp.MustCompile("&[a-z]+;"), ""},
-	{regexp.MustCompile("<[^>]+>"), ""},
-	{regexp.MustCompile("\\{\\{[^}]+(\\}\\}|$)"), ""},
-	{regexp.MustCompile("[{}]"), ""},
-	{regexp.MustCompile("\\[\\[(Kategori|категория):"), "[["},
-	{regexp.MustCompile("\\[\\[[A-Za-z]+:([^|\\]]+\\|)+"), "[["},
-	{regexp.MustCompile("\\[\\[([^|\\]]+)\\|?\\]\\]"), "$1"},
-	{regexp.MustCompile("\\[\\[(?:[^|\\]]+)\\|([^|\\]]+)\\]\\]"), "$1"},
-	{regexp.MustCompile("\\[\\[(?:[^|\\]]+)(?:\\|(?:[^|\\]]+))*\\|([^|\\]]+)\\]\\]"), "$1"},
-	{regexp.MustCompile("[\\[\\]]+"), ""},
-	{regexp.MustCompile("==+"), ""},
-	{regexp.MustCompile(" ' "), " "},
-	{regexp.MustCompile("(: | :)"), " "},
-	{regexp.MustCompile("[\\]\\[!\"”#$%&()*+,./;<=>?@\\^_`{|}~\\s\u00a0–]+"), " "},
-	{regexp.MustCompile("(( |^)'+|'+( |$))"), " "},
-	{regexp.MustCompile("( *- | - *)"), " "},
-}
-var lineReplacements = []replacement{
-	{regexp.MustCompile("&lt;"), "<"},
-	{regexp.MustCompile("&gt;"), ">"},
-	{regexp.MustCompile("&quot;"), "\""},
-	{regexp.MustCompile("&amp;"), "&"},
-	{regexp.MustCompile("^ *<text[^>]*>"), ""},
-	{regexp.MustCompile("#REDIRECT "), ""},
-	{regexp.MustCompile("^ *:;?"), ""},
-}
-var skipRe = regexp.MustCompile("^ *(!|\\||<|\\{\\||&|<redirect[^>]+>).*")
+const (
+	parserWikitext = "wikitext"
+	parserLines    = "lines"
+)
+
+// tokenizerOptions are the user options for the tokenizer
+type tokenizerOptions struct {
+	parser    string // wikitext (default) or lines
+	tokenizer string // regexp (default) or uax29 (see uax29.go)
+	lang      string // language profile (see profiles.go), inferred from the siteinfo if empty
+	keepCase  bool   // count surface forms, without lower casing (see case.go)
+	sentences string // sentence splitter for the wikitext parser: rules (default) or punkt (see punkt.go)
+	punkt     *punktModel
+}
+
+// tokenizer holds the cleanup rules used to split page text into words. The rules for links and
+// lines to skip depend on the namespaces of the wiki (see siteinfo.go).
+type tokenizer struct {
+	opts               tokenizerOptions
+	profile            langProfile
+	tokenReplacements  []replacement // used by the line based parser
+	markupReplacements []replacement // the tokenReplacements for markup, used by the line based parser with the uax29 tokenizer
+	lineReplacements   []replacement // used by the line based parser
+	textReplacements   []replacement // used for plain text, after parsing the wikitext
+	skipRe             *regexp.Regexp
+	userLinkRe         *regexp.Regexp
+	abbreviationRe     *regexp.Regexp // abbreviations of the language profile, or nil
+	abbreviations      map[string]bool
+	namespaceKeys      map[string]int
+	imageOptionRe      *regexp.Regexp // image options of file links, used by the wikitext parser
+}
+
+func newTokenizer(si SiteInfo, opts tokenizerOptions) *tokenizer {
+	var categories = namespacePattern(si.namespaceNames(categoryNamespace))
+	var users = namespacePattern(append(si.namespaceNames(userNamespace), si.namespaceNames(userTalkNamespace)...))
+	var others = namespacePattern(si.otherNamespaceNames())
+	var tk = tokenizer{opts: opts, profile: profileFor(opts.lang, si), namespaceKeys: si.namespaceKeys()}
+	tk.imageOptionRe = regexp.MustCompile(imageOptionPattern(si.imageOptionNames()))
+	var pre, post = tk.profile.replacements()
+	var punctuationReplacements = append(pre, []replacement{
+		{regexp.MustCompile(" ' "), " "},
+		{regexp.MustCompile("(: | :)"), " "},
+		{regexp.MustCompile("[\\]\\[!\"”#$%&()*+,./;<=>?@\\^_`{|}~\\s\u00a0–]+"), " "},
+		{regexp.MustCompile("(( |^)'+|'+( |$))"), " "},
+		{regexp.MustCompile("( *- | - *)"), " "},
+	}...)
+	punctuationReplacements = append(punctuationReplacements, post...)
+	tk.abbreviationRe = tk.profile.abbreviationRe()
+	tk.abbreviations = tk.profile.abbreviationSet()
+	tk.textReplacements = append([]replacement{
+		{regexp.MustCompile("[«»]"), "\""},
+	}, punctuationReplacements...)
+	tk.markupReplacements = []replacement{
+		// '''
+		{regexp.MustCompile("'''"), "\""},
+		{regexp.MustCompile("''"), "\""},
+		{regexp.MustCompile("[«»]"), "\""},
+		{regexp.MustCompile("http://[^\\s]+"), ""},
+		{regexp.MustCompile("&lt;!--"), "<!--"},
+		{regexp.MustCompile("--&gt;"), "-->"},
+		{regexp.MustCompile("<!--[^>]+-->"), ""},
+		{regexp.MustCompile("(&lt;|<)/?ref( |(&gt;|>)).*$"), ""},
+		{regexp.MustCompile("&quot;"), "\""},
+		{regexp.MustCompile("&amp;"), "&"},
+		{regexp.MustCompile("^ *\\* *"), ""},
+		{regexp.MustCompile("&[a-z]+;"), ""},
+		{regexp.MustCompile("<[^>]+>"), ""},
+		{regexp.MustCompile("\\{\\{[^}]+(\\}\\}|$)"), ""},
+		{regexp.MustCompile("[{}]"), ""},
+		{regexp.MustCompile("\\[\\[" + categories + ":"), "[["},
+		{regexp.MustCompile("\\[\\[(?:" + others + "|[a-z][a-z-]*):([^|\\]]+\\|)+"), "[["},
+		{regexp.MustCompile("\\[\\[([^|\\]]+)\\|?\\]\\]"), "$1"},
+		{regexp.MustCompile("\\[\\[(?:[^|\\]]+)\\|([^|\\]]+)\\]\\]"), "$1"},
+		{regexp.MustCompile("\\[\\[(?:[^|\\]]+)(?:\\|(?:[^|\\]]+))*\\|([^|\\]]+)\\]\\]"), "$1"},
+		{regexp.MustCompile("[\\[\\]]+"), ""},
+		{regexp.MustCompile("==+"), ""},
+	}
+	tk.tokenReplacements = append(append([]replacement{}, tk.markupReplacements...), punctuationReplacements...)
+	tk.lineReplacements = []replacement{
+		{regexp.MustCompile("&lt;"), "<"},
+		{regexp.MustCompile("&gt;"), ">"},
+		{regexp.MustCompile("&quot;"), "\""},
+		{regexp.MustCompile("&amp;"), "&"},
+		{regexp.MustCompile("^ *<text[^>]*>"), ""},
+		{regexp.MustCompile("#REDIRECT "), ""},
+		{regexp.MustCompile("^ *:;?"), ""},
+	}
+	tk.skipRe = regexp.MustCompile("^ *(!|\\||<|\\{\\||&|<redirect[^>]+>).*")
+	tk.userLinkRe = regexp.MustCompile("\\[\\[" + users + ":")
+	return &tk
+}
+
+// defaultTokenizer is used for dumps without siteinfo
+var defaultTokenizer = newTokenizer(defaultSiteInfo, tokenizerOptions{})
 
 // end: pre-compiled regexps
 
-func tokenizeLine(l string) []string {
-	l = convert(l)
+func (tk *tokenizer) convert(s string) string {
+	result := tk.markAbbreviations(s)
+	for _, repl := range tk.tokenReplacements {
+		result = repl.From.ReplaceAllString(result, repl.To)
+	}
+	return tk.fold(strings.TrimSpace(result))
+}
+
+// fold lower cases s, unless the case is kept
+func (tk *tokenizer) fold(s string) string {
+	if tk.opts.keepCase {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+func (tk *tokenizer) tokenizeLine(l string) []string {
+	if tk.opts.tokenizer == tokenizerUAX29 {
+		for _, repl := range tk.markupReplacements {
+			l = repl.From.ReplaceAllString(l, repl.To)
+		}
+		return tk.profile.tailor(segmentWords(tk.fold(l)))
+	}
+	l = tk.convert(l)
 	return splitWhiteSpace(l)
 }
 
-func preFilterLine(l string) string {
+func (tk *tokenizer) preFilterLine(l string) string {
 	result := l
-	for _, repl := range lineReplacements {
+	for _, repl := range tk.lineReplacements {
 		result = repl.From.ReplaceAllString(result, repl.To)
 	}
 	return result
 }
 
-func skip(l string) bool {
+func (tk *tokenizer) skip(l string) bool {
 	l = strings.TrimSpace(l)
-	return (!strings.HasPrefix(l, "<page") && !strings.HasPrefix(l, "<text") && (skipRe.MatchString(l) || strings.Contains(l, "[[Användar") || strings.Contains(l, "<comment>")))
+	return (!strings.HasPrefix(l, "<page") && !strings.HasPrefix(l, "<text") && (tk.skipRe.MatchString(l) || tk.userLinkRe.MatchString(l) || strings.Contains(l, "<comment>")))
 }
 
 func lIntRoundToString(i int) string {
@@ -207,146 +363,737 @@ func clearProgress() {
 	fmt.Fprint(os.Stderr, withPadding)
 }
 
-func tokenizeText(text string) (nLines int, nLinesSkipped int, wordFreqs map[string]int) {
+// tokenizePlainLine splits a line of plain text (output from the wikitext parser) into words
+func (tk *tokenizer) tokenizePlainLine(l string) []string {
+	if tk.opts.tokenizer == tokenizerUAX29 {
+		return tk.profile.tailor(segmentWords(tk.fold(l)))
+	}
+	l = tk.markAbbreviations(l)
+	for _, repl := range tk.textReplacements {
+		l = repl.From.ReplaceAllString(l, repl.To)
+	}
+	return splitWhiteSpace(tk.fold(strings.TrimSpace(l)))
+}
+
+func (tk *tokenizer) tokenizeText(text string) (nLines int, nLinesSkipped int, wordFreqs map[string]int) {
+	nLines, nLinesSkipped, sentences := tk.tokenizeSentences(text)
+	return nLines, nLinesSkipped, countWords(sentences)
+}
+
+func countWords(sentences [][]string) map[string]int {
+	var wordFreqs = make(map[string]int)
+	for _, words := range sentences {
+		for _, word := range words {
+			wordFreqs[word]++
+		}
+	}
+	return wordFreqs
+}
+
+// splitSentences splits a line of plain text into sentences, using the punkt model if set
+func (tk *tokenizer) splitSentences(line string) []string {
+	if tk.opts.punkt != nil {
+		return tk.opts.punkt.split(line, tk.abbreviations)
+	}
+	return splitSentences(line, tk.abbreviations)
+}
+
+// sentence is a sentence of plain text, with its words, and the index of the line it is from (of the plain text
+// for the wikitext parser, and of the wikitext for the line based parser)
+type sentence struct {
+	line  int
+	text  string
+	words []string
+}
+
+// splitText splits the text into sentences. With the line based parser, each line is treated as a sentence, and
+// the text of the sentence is its words.
+func (tk *tokenizer) splitText(text string) (nLines int, nLinesSkipped int, sentences []sentence) {
 	nLines = 0
 	nLinesSkipped = 0
-	wordFreqs = make(map[string]int)
-	for _, l0 := range strings.Split(text, "\n") {
+	if tk.opts.parser != parserLines {
+		// lines of the wikitext that do not result in any plain text are counted as skipped
+		nLines = strings.Count(text, "\n") + 1
+		nLinesSkipped = nLines
+		for i, line := range strings.Split(tk.parseWikitext(text), "\n") {
+			var found = false
+			for _, s := range tk.splitSentences(line) {
+				words := tk.tokenizePlainLine(s)
+				if len(words) > 0 {
+					found = true
+					sentences = append(sentences, sentence{line: i, text: strings.TrimSpace(s), words: words})
+				}
+			}
+			if found {
+				nLinesSkipped--
+			}
+		}
+		if nLinesSkipped < 0 {
+			nLinesSkipped = 0
+		}
+		return nLines, nLinesSkipped, sentences
+	}
+	for i, l0 := range strings.Split(text, "\n") {
 		nLines++
-		line := preFilterLine(l0)
-		if skip(line) {
+		line := tk.preFilterLine(l0)
+		if tk.skip(line) {
 			nLinesSkipped++
 		} else {
-			words := tokenizeLine(line)
+			words := tk.tokenizeLine(line)
 			if len(words) > 0 {
-				for _, word := range words {
-					wordFreqs[word]++
-				}
+				sentences = append(sentences, sentence{line: i, text: strings.Join(words, " "), words: words})
 			}
 		}
 	}
-	return nLines, nLinesSkipped, wordFreqs
+	return nLines, nLinesSkipped, sentences
+}
+
+func sentenceWords(sentences []sentence) [][]string {
+	var result = make([][]string, len(sentences))
+	for i, s := range sentences {
+		result[i] = s.words
+	}
+	return result
+}
+
+// tokenizeSentences splits the text into sentences of words. With the line based parser, each line is
+// treated as a sentence.
+func (tk *tokenizer) tokenizeSentences(text string) (nLines int, nLinesSkipped int, sentences [][]string) {
+	nLines, nLinesSkipped, ss := tk.splitText(text)
+	return nLines, nLinesSkipped, sentenceWords(ss)
 }
 
 type loadResult struct {
 	nPages        int
 	nRedirects    int
+	nRevisions    int
 	nLines        int
 	nLinesSkipped int
 	nWords        int
+	nNgrams       int
 	wordFreqs     map[string]int
+	ngramFreqs    map[string]int // n-gram counts (if opts.ngramN > 1)
+	docFreqs      map[string]int // no. of pages per word (if opts.docFreqs)
+	ngramDocFreqs map[string]int // no. of pages per n-gram (if opts.docFreqs and opts.ngramN > 1)
+	partFreqs     map[string]partFreqs
+	partSizes     []int         // no. of words per corpus part
+	bucketFreqs   bucketFreqs   // counts per time bucket (if opts.timeBuckets is set)
+	caseFreqs     *caseFreqs    // sentence internal occurrences (if opts.caseVariants)
+	sentLengths   map[int]int   // no. of sentences of each length (in words)
+	corpus        *corpusWriter // export of the cleaned text (optional)
+	spill         *spiller      // writes the counts to disk when the memory budget is exceeded (optional)
+	approxWords   *spaceSaving  // approximate word counts, used instead of wordFreqs (optional)
+	approxNgrams  *spaceSaving
+	nsPages       map[int]int // no. of pages per namespace, including namespaces not counted
+	siteInfo      SiteInfo
+	tk            *tokenizer
+	pagesRead     int // no. of pages read, including pages not counted
+	lastPageID    int
+	skipPages     int // no. of pages to skip before counting (when resuming from a checkpoint without input offset)
 }
 
-func loadXML(path string, pageLimit int, logAt int) loadResult {
-	var decoder *xml.Decoder
+func newLoadResult(opts loadOptions) loadResult {
+	var result = loadResult{}
+	result.nLines = 0
+	result.nLinesSkipped = 0
+	result.nPages = 0
+	result.nRedirects = 0
+	result.nWords = 0
+	result.wordFreqs = make(map[string]int)
+	result.ngramFreqs = make(map[string]int)
+	if opts.counter == counterSpaceSaving {
+		result.approxWords = newSpaceSaving(opts.capacity)
+		if opts.ngramN > 1 {
+			result.approxNgrams = newSpaceSaving(opts.capacity)
+		}
+	}
+	if opts.memBudget > 0 {
+		result.spill = newSpiller(opts.spillDir, opts.memBudget)
+	}
+	if opts.docFreqs {
+		result.docFreqs = make(map[string]int)
+		result.ngramDocFreqs = make(map[string]int)
+	}
+	if opts.dispersionParts > 0 {
+		result.partFreqs = make(map[string]partFreqs)
+		result.partSizes = make([]int, opts.dispersionParts)
+	}
+	if opts.timeBuckets != "" {
+		result.bucketFreqs = make(bucketFreqs)
+	}
+	if opts.caseVariants {
+		result.caseFreqs = newCaseFreqs()
+	}
+	result.nsPages = make(map[int]int)
+	result.sentLengths = make(map[int]int)
+	result.corpus = opts.corpus
+	result.tk = newTokenizer(defaultSiteInfo, opts.tkOpts)
+	return result
+}
+
+type loadOptions struct {
+	pageLimit  int
+	logAt      int
+	index      string        // multistream index file (optional)
+	selection  pageSelection // pages to read (optional)
+	workers    int           // number of parallel workers for multistream bz2 dumps
+	nsAliases  []Namespace   // namespace aliases, in addition to the namespaces listed in the dump's siteinfo
+	imageOpts  []string      // image option names, in addition to the canonical and local names
+	namespaces map[int]bool  // namespaces to count (nil = all)
+	tkOpts     tokenizerOptions
+	checkpoint *checkpointer // periodic checkpoints (optional)
+	resume     bool          // resume from the last checkpoint
+	checksum   *checksum     // checksum of the input file (optional)
+	ngramN     int           // n-gram size (no n-gram counting if < 2)
+	// no. of corpus parts for the dispersion measures (no dispersion if 0)
+	dispersionParts int
+	docFreqs        bool          // count the no. of pages per word and n-gram (required for dispersion)
+	memBudget       int           // memory budget in bytes for the word and n-gram counts (no limit if 0)
+	spillDir        string        // directory for temporary files, when the memory budget is exceeded
+	counter         string        // counterExact or counterSpaceSaving (approximate counts)
+	capacity        int           // max no. of words (and n-grams) kept by the approximate counter
+	revisions       string        // revisionsAll or revisionsLatest, for pages with several revisions
+	timeBuckets     string        // bucketYear or bucketMonth, for time series (optional)
+	caseVariants    bool          // count the sentence internal occurrences of the words, for the case variants (with tkOpts.keepCase)
+	punktPages      int           // no. of pages to train the punkt sentence splitter on
+	punktModel      string        // punkt model file (optional)
+	corpus          *corpusWriter // export of the cleaned text (optional)
+}
+
+// outputOptions are the options for printing the result
+type outputOptions struct {
+	minFreq      int
+	ngramMinFreq int
+	ngramFile    string
+	format       string // output format (see output.go)
+	file         string // output file (stdout if empty)
+	timeFile     string // output file for the time series (if opts.timeBuckets is set)
+	caseFile     string // output file for the case variants (if opts.caseVariants is set)
+	corpusFile   string // output file for the cleaned text (optional)
+	corpusFormat string // corpusJSONL or corpusText
+	manifest     string // manifest file (optional, see manifest.go)
+	flags        map[string]string
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// openRawInput opens a local file or url
+func openRawInput(path string) (io.ReadCloser, error) {
+	return openRawInputAt(path, 0)
+}
+
+// openRawInputAt opens a local file or url, starting at the byte offset
+func openRawInputAt(path string, offset int64) (io.ReadCloser, error) {
+	path = download.cached(path)
 	if strings.HasPrefix(path, "http") {
-		response, err := http.Get(path)
+		if offset == 0 && download.cacheDir != "" {
+			return openCachedURL(path, download)
+		}
+		return openURL(path, offset, -1, download)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// openInput opens a local file or url, decompressing it on the fly if it ends with bz2
+func openInput(path string) (io.ReadCloser, error) {
+	rc, err := openRawInput(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, "bz2") {
+		return readCloser{bzip2.NewReader(rc), rc}, nil
+	}
+	return rc, nil
+}
+
+func loadXML(path string, opts loadOptions) loadResult {
+	if opts.index != "" && !opts.selection.isEmpty() {
+		return loadMultistream(path, opts)
+	}
+
+	var result = newLoadResult(opts)
+	var offset int64
+	if opts.resume {
+		cp, err := readCheckpoint(opts.checkpoint.path)
 		if err != nil {
 			log.Fatal(err)
 		}
-		if response.StatusCode != 200 {
-			log.Fatal(response.Status + " " + path)
+		result, err = cp.resume(path, opts)
+		if err != nil {
+			log.Fatal(err)
 		}
-		defer response.Body.Close()
-		if strings.HasSuffix(path, "bz2") {
-			bz := bzip2.NewReader(response.Body)
-			decoder = xml.NewDecoder(bz)
-		} else {
-			decoder = xml.NewDecoder(response.Body)
+		if cp.Offset > 0 {
+			offset = cp.Offset
 		}
+		opts.checkpoint.lastPagesRead = cp.PagesRead
+		log.Print(fmt.Sprintf("Resuming after %d pages (last page id %d, input offset %d)", cp.PagesRead, cp.LastPageID, cp.Offset))
+	}
+
+	// multistream dumps are split into separate bz2 streams when running in parallel, or with checkpoints at stream boundaries
+	// (not when resuming from a checkpoint without input offset, since all pages before the checkpoint need to be skipped)
+	if strings.HasSuffix(path, "bz2") && result.skipPages == 0 && (opts.workers > 1 || (opts.checkpoint != nil && strings.Contains(path, "multistream"))) {
+		return loadParallel(path, opts, result, offset)
+	}
+
+	rawInput, err := openRawInputAt(path, offset)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rawInput.Close()
+	input := opts.checksum.wrap(rawInput)
+
+	var decoder *xml.Decoder
+	var inputOffset func() int64
+	if strings.HasSuffix(path, "bz2") {
+		decoder = xml.NewDecoder(bzip2.NewReader(input))
 	} else {
-		file, err := os.Open(path)
+		decoder = xml.NewDecoder(input)
+		inputOffset = func() int64 { return offset + decoder.InputOffset() }
+	}
+	readPages(decoder, &result, opts, inputOffset)
+	if err := opts.checksum.verify(input, path); err != nil {
+		log.Fatal(err)
+	}
+	return result
+}
+
+// pageResult holds the counts for a single page
+type pageResult struct {
+	ns            int
+	excluded      bool // not in the namespaces to count
+	redirect      bool
+	nLines        int
+	nLinesSkipped int
+	nRevisions    int // no. of revisions counted
+	wordFreqs     map[string]int
+	ngramFreqs    map[string]int
+	bucketFreqs   bucketFreqs // counts per time bucket (if opts.timeBuckets is set)
+	caseFreqs     *caseFreqs  // sentence internal occurrences (if opts.caseVariants is set)
+	sentLengths   []int       // the length of each sentence (in words)
+	corpusDocs    []corpusDoc // the text of the counted revisions (if opts.corpus is set)
+}
+
+// decodePage decodes the elements of a page (after its start element) one at a time, and calls revision for each
+// revision, so that the revisions of a page in a history dump are not all held in memory. The title, namespace,
+// id and redirect are set before the first revision. If revision returns false, the rest of the page is skipped.
+func decodePage(decoder *xml.Decoder, revision func(p Page, rev Revision) bool) (Page, error) {
+	var p Page
+	for {
+		t, err := decoder.Token()
 		if err != nil {
-			log.Fatal(err)
+			return p, err
 		}
-		defer file.Close()
-		if strings.HasSuffix(path, "bz2") {
-			bz := bzip2.NewReader(file)
-			decoder = xml.NewDecoder(bz)
-		} else {
-			decoder = xml.NewDecoder(file)
+		switch el := t.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "title":
+				err = decoder.DecodeElement(&p.Title, &el)
+			case "ns":
+				err = decoder.DecodeElement(&p.NS, &el)
+			case "id":
+				err = decoder.DecodeElement(&p.ID, &el)
+			case "redirect":
+				err = decoder.DecodeElement(&p.Redir, &el)
+			case "revision":
+				var rev Revision
+				err = decoder.DecodeElement(&rev, &el)
+				if err == nil && !revision(p, rev) {
+					return p, decoder.Skip()
+				}
+			default:
+				err = decoder.Skip()
+			}
+			if err != nil {
+				return p, err
+			}
+		case xml.EndElement:
+			return p, nil
 		}
 	}
+}
 
-	var result = loadResult{}
-	result.nLines = 0
-	result.nLinesSkipped = 0
-	result.nPages = 0
-	result.nRedirects = 0
-	result.nWords = 0
-	result.wordFreqs = make(map[string]int)
+// readPage decodes and counts a page (after its start element), one revision at a time. It returns false if the
+// page is not selected (and not counted).
+func readPage(decoder *xml.Decoder, tk *tokenizer, opts loadOptions) (Page, pageResult, bool) {
+	var pc *pageCounter
+	var selected bool
+	var start = func(p Page) {
+		selected = opts.selection.isEmpty() || opts.selection.accept(p.ID, p.Title)
+		pc = newPageCounter(p, tk, opts)
+	}
+	p, _ := decodePage(decoder, func(p Page, rev Revision) bool {
+		if pc == nil {
+			start(p)
+		}
+		if !selected || pc.pr.excluded || pc.pr.redirect {
+			return false
+		}
+		pc.add(rev)
+		return true
+	})
+	if pc == nil {
+		start(p)
+	}
+	return p, pc.result(), selected
+}
+
+// pageCounter counts the revisions of a page as they are decoded. For the latest revision, only the last revision
+// read is kept, and the previous one is counted for its time bucket (if it was the last revision of the bucket).
+type pageCounter struct {
+	p      Page
+	tk     *tokenizer
+	opts   loadOptions
+	pr     pageResult
+	latest *Revision      // the last revision read, counted at the end of the page (for revisionsLatest)
+	prev   map[string]int // the counts of the last revision added to a time bucket (for revisionsLatest)
+}
+
+func newPageCounter(p Page, tk *tokenizer, opts loadOptions) *pageCounter {
+	var pc = &pageCounter{p: p, tk: tk, opts: opts, pr: pageResult{ns: p.NS}}
+	if opts.namespaces != nil && !opts.namespaces[p.NS] {
+		pc.pr.excluded = true
+		return pc
+	}
+	if len(p.Redir.Title) > 0 {
+		pc.pr.redirect = true
+		return pc
+	}
+	pc.pr.wordFreqs = make(map[string]int)
+	if opts.ngramN > 1 {
+		pc.pr.ngramFreqs = make(map[string]int)
+	}
+	if opts.timeBuckets != "" {
+		pc.pr.bucketFreqs = make(bucketFreqs)
+	}
+	if opts.caseVariants {
+		pc.pr.caseFreqs = newCaseFreqs()
+	}
+	return pc
+}
+
+// tokenize splits the text of a revision into sentences (the title is counted as the first line of the text)
+func (pc *pageCounter) tokenize(rev Revision) (nLines int, nLinesSkipped int, sentences []sentence) {
+	var text = rev.Text
+	if len(pc.p.Title) > 0 {
+		text = pc.p.Title + "\n" + text
+	}
+	return pc.tk.splitText(text)
+}
+
+// count adds a counted revision, and returns its word counts
+func (pc *pageCounter) count(rev Revision) map[string]int {
+	nL, nLS, ss := pc.tokenize(rev)
+	var sentences = sentenceWords(ss)
+	var freqs = countWords(sentences)
+	pc.pr.nRevisions++
+	pc.pr.nLines += nL
+	pc.pr.nLinesSkipped += nLS
+	for w, f := range freqs {
+		pc.pr.wordFreqs[w] += f
+	}
+	for _, words := range sentences {
+		pc.pr.sentLengths = append(pc.pr.sentLengths, len(words))
+	}
+	if pc.opts.ngramN > 1 {
+		for ng, f := range countNgrams(sentences, pc.opts.ngramN) {
+			pc.pr.ngramFreqs[ng] += f
+		}
+	}
+	if pc.pr.caseFreqs != nil {
+		pc.pr.caseFreqs.addSentences(sentences)
+	}
+	if pc.opts.corpus != nil {
+		pc.pr.corpusDocs = append(pc.pr.corpusDocs, newCorpusDoc(pc.p, rev, len(pc.p.Title) > 0, ss))
+	}
+	return freqs
+}
+
+// add counts a revision, or keeps it until the next revision is read (for revisionsLatest)
+func (pc *pageCounter) add(rev Revision) {
+	if pc.opts.revisions != revisionsLatest {
+		freqs := pc.count(rev)
+		if b, ok := timeBucket(rev.Timestamp, pc.opts.timeBuckets); ok && pc.opts.timeBuckets != "" {
+			pc.pr.bucketFreqs.add(b, freqs, 1)
+		}
+		return
+	}
+	// only the last revision of each bucket is counted for the time series (the revisions are in chronological order)
+	if pc.latest != nil && pc.opts.timeBuckets != "" {
+		b, ok := timeBucket(pc.latest.Timestamp, pc.opts.timeBuckets)
+		next, nextOK := timeBucket(rev.Timestamp, pc.opts.timeBuckets)
+		if ok && !(nextOK && next == b) {
+			_, _, ss := pc.tokenize(*pc.latest)
+			pc.addBucket(b, countWords(sentenceWords(ss)))
+		}
+	}
+	pc.latest = &rev
+}
+
+// addBucket adds the change of the page at the end of a time bucket (for revisionsLatest)
+func (pc *pageCounter) addBucket(b string, freqs map[string]int) {
+	pc.pr.bucketFreqs.add(b, freqs, 1)
+	pc.pr.bucketFreqs.add(b, pc.prev, -1)
+	pc.prev = freqs
+}
 
+// result counts the latest revision (for revisionsLatest), and returns the counts of the page
+func (pc *pageCounter) result() pageResult {
+	if pc.latest != nil {
+		freqs := pc.count(*pc.latest)
+		if b, ok := timeBucket(pc.latest.Timestamp, pc.opts.timeBuckets); ok && pc.opts.timeBuckets != "" {
+			pc.addBucket(b, freqs)
+		}
+		pc.latest = nil
+	}
+	return pc.pr
+}
+
+func (result *loadResult) addPage(pr pageResult, logAt int) {
+	result.nsPages[pr.ns]++
+	if pr.excluded {
+		return
+	}
+	result.nPages++
+	if pr.redirect {
+		result.nRedirects++
+	} else {
+		result.nRevisions += pr.nRevisions
+		result.nLines += pr.nLines
+		result.nLinesSkipped += pr.nLinesSkipped
+		for _, f := range pr.wordFreqs {
+			result.nWords += f
+		}
+		for _, f := range pr.ngramFreqs {
+			result.nNgrams += f
+		}
+		for _, l := range pr.sentLengths {
+			result.sentLengths[l]++
+		}
+		switch {
+		case result.approxWords != nil:
+			result.approxWords.addAll(pr.wordFreqs)
+			if result.approxNgrams != nil {
+				result.approxNgrams.addAll(pr.ngramFreqs)
+			}
+		case result.spill != nil:
+			for w, f := range pr.wordFreqs {
+				result.spill.add(result.wordFreqs, w, f)
+			}
+			for ng, f := range pr.ngramFreqs {
+				result.spill.add(result.ngramFreqs, ng, f)
+			}
+		default:
+			for w, f := range pr.wordFreqs {
+				result.wordFreqs[w] += f
+			}
+			for ng, f := range pr.ngramFreqs {
+				result.ngramFreqs[ng] += f
+			}
+		}
+		result.spill.spillIfDue(result)
+		if result.docFreqs != nil {
+			for w := range pr.wordFreqs {
+				result.docFreqs[w]++
+			}
+			for ng := range pr.ngramFreqs {
+				result.ngramDocFreqs[ng]++
+			}
+		}
+		if len(result.partSizes) > 0 {
+			result.addDispersion(pr.wordFreqs)
+		}
+		if result.bucketFreqs != nil {
+			result.addBuckets(pr.bucketFreqs)
+		}
+		if result.caseFreqs != nil {
+			result.caseFreqs.addAll(pr.caseFreqs)
+		}
+		if result.corpus != nil {
+			result.corpus.write(pr.corpusDocs)
+		}
+	}
+	if result.nPages%logAt == 0 {
+		printProgress(result.nPages, result.nLines, result.nWords)
+	}
+}
+
+// setSiteInfo sets the siteinfo of the dump, and the tokenizer rules for its namespaces
+func (result *loadResult) setSiteInfo(si SiteInfo, opts loadOptions) {
+	si.Aliases = append(si.Aliases, opts.nsAliases...)
+	si.ImageOptions = append(si.ImageOptions, opts.imageOpts...)
+	result.siteInfo = si
+	result.tk = newTokenizer(si, opts.tkOpts)
+}
+
+func pageLimitReached(result loadResult, pageLimit int) bool {
+	if pageLimit > 0 && result.nPages >= pageLimit {
+		clearProgress()
+		log.Println(fmt.Sprintf("Break called at %d pages (limit set by user)", result.nPages))
+		return true
+	}
+	return false
+}
+
+// readPages reads pages from the decoder into result. It returns false if the page limit was reached.
+// If inputOffset is set, it is used to save the raw input offset in checkpoints.
+func readPages(decoder *xml.Decoder, result *loadResult, opts loadOptions, inputOffset func() int64) bool {
 	for {
 		t, _ := decoder.Token()
 		if t == nil {
 			break
 		}
-		if pageLimit > 0 && result.nPages >= pageLimit {
-			clearProgress()
-			log.Println(fmt.Sprintf("Break called at %d pages (limit set by user)", result.nPages))
-			break
+		if pageLimitReached(*result, opts.pageLimit) {
+			return false
 		}
 		switch se := t.(type) {
 		case xml.StartElement:
+			if se.Name.Local == "siteinfo" {
+				var si SiteInfo
+				decoder.DecodeElement(&si, &se)
+				result.setSiteInfo(si, opts)
+			}
 			if se.Name.Local == "page" {
-				var p Page
-				result.nPages++
-				decoder.DecodeElement(&p, &se)
-				var text = p.Text
-				var title = p.Title
-				if len(title) > 0 {
-					text = title + "\n" + text
+				if result.skipPages > 0 {
+					result.skipPages--
+					decoder.Skip()
+					continue
 				}
-				var redirect = p.Redir.Title
-				if len(redirect) > 0 {
-					result.nRedirects++
-				} else {
-					nL, nLS, wFs := tokenizeText(text)
-					result.nLines += nL
-					result.nLinesSkipped += nLS
-					for w, f := range wFs {
-						result.nWords += f
-						result.wordFreqs[w] += f
-					}
+				p, pr, selected := readPage(decoder, result.tk, opts)
+				result.pagesRead++
+				result.lastPageID = p.ID
+				if selected {
+					result.addPage(pr, opts.logAt)
 				}
-				if result.nPages%logAt == 0 {
-					printProgress(result.nPages, result.nLines, result.nWords)
+				if opts.checkpoint.due(result.pagesRead) {
+					var offset int64 = -1
+					if inputOffset != nil {
+						offset = inputOffset()
+					}
+					opts.checkpoint.saveIfDue(*result, offset)
 				}
 			}
 		}
 	}
-	return result
+	return true
 }
 
-func loadCmdLineArgs() (int, int, string) {
+func loadCmdLineArgs(args []string) (loadOptions, outputOptions, string) {
 	var usage = `
-wstats is used for parsing wikimedia dump files on the fly into word frequency lists.
-
-It is NOT ready for proper use, so use at your own risk.
+wstats count parses a wikimedia dump file on the fly into a word frequency list.
 
 The program will print running progress and basic statistics to standard error.\nA complete word frequency list will be printed to standard out (limited by min freq, if set).
 
 Usage:
- $ go run wstats.go <flags> <wikipedia dump path (file or url, xml or xml.bz2)>
+ $ go run . [count] <flags> <wikipedia dump path (file or url, xml or xml.bz2)>
 
 Cmd line flags:
-  -pl int     page limit: limit number of pages to read (optional, default = unset)
-  -mf int     min freq: lower limit for word frequencies to be printed (optional, default = 0)
-  -h(elp)     help: print help message
+  -pl int              page limit: limit number of pages to read (optional, default = unset)
+  -mf int              min freq: lower limit for word frequencies to be printed (optional, default = 0)
+  -format string       output format: tsv, jsonl, csv (with header), sqlite or parquet (optional, default = tsv)
+  -out string          output file (optional, default = standard out, required for sqlite)
+  -index string        multistream index file (file or url), used for random access to the pages selected by -titles, -ids or -idrange (optional)
+  -titles string       file with page titles to read, one per line (optional)
+  -ids string          comma separated list of page ids to read (optional)
+  -idrange string      page id range to read, <from>-<to> (optional)
+  -workers int         number of parallel workers for multistream bz2 dumps (optional, default = 1)
+  -nsaliases string    namespace aliases (file or url), in MediaWiki api json format (optional)
+  -magicwords string   magic words (file or url), in MediaWiki api json format, for the local names of image options (optional)
+  -ns string           namespaces to count: comma separated list of namespace keys, or all (optional, default = 0)
+  -parser string       wikitext parser: wikitext (full parser) or lines (line based regexps) (optional, default = wikitext)
+  -tokenizer string    word tokenizer: regexp (punctuation regexps) or uax29 (Unicode word boundaries, with NFC normalisation) (optional, default = regexp)
+  -lang string         language profile for the tokenizer (apostrophes, hyphens, colons and abbreviations): generic, en, fi, fr or sv (optional, default = inferred from the dbname of the dump, or generic)
+  -checkpoint string   checkpoint file, saved periodically during the run (optional)
+  -cpevery int         no. of pages between checkpoints (optional, default = 100000)
+  -resume              resume from the checkpoint file, after an interrupted run (optional)
+  -retries int         no. of retries on download errors, resuming at the current position (optional, default = 5)
+  -backoff duration    wait before retrying a download, doubled for each retry (optional, default = 1s)
+  -timeout duration    download timeout: max wait for a response, or for data from an open connection, before retrying (0 for none) (optional, default = 1m0s)
+  -cache string        cache directory for downloaded files, used instead of the url on the next run (optional)
+  -checksums string    checksums file (file or url, md5sums or sha1sums), to verify the input file (optional)
+  -ngram int           n-gram size: count word n-grams within sentences, e.g. 2 for bigrams (optional)
+  -ngrammf int         min freq for n-grams to be printed (optional, default = 0)
+  -ngramfile string    output file for the n-gram frequency list (required with -ngram, except for sqlite)
+  -dispersion int      no. of corpus parts for dispersion: adds document frequency, Juilland's D and Gries' DP to the output (optional)
+  -maxmem int          memory budget in MB for the word and n-gram counts, written to temporary files when exceeded (optional, default = unset)
+  -tmpdir string       directory for temporary files (optional, default = system temp dir)
+  -counter string      word counter: exact, or spacesaving (approximate counts in fixed memory) (optional, default = exact)
+  -capacity int        no. of words (and n-grams) kept by the spacesaving counter (optional, default = 100000)
+  -manifest string     JSON manifest with the statistics, input checksum and settings of the run (optional, default = <out>.manifest.json if -out is set)
+  -revisions string    revisions to count, for pages-meta-history dumps: all or latest (optional, default = all)
+  -timebuckets string  split the counts by revision timestamp into a time series: year or month (optional)
+  -timefile string     output file for the time series (required with -timebuckets, except for sqlite)
+  -case                count surface forms: don't lower case the words (optional)
+  -casefile string     output file for the case variants of each word: total count, most frequent form, share capitalised within sentences (requires -case and the wikitext parser, optional for sqlite)
+  -sentences string    sentence splitter for the wikitext parser: rules (punctuation followed by a capital letter) or punkt (unsupervised, trained on the dump) (optional, default = rules)
+  -punktpages int      no. of pages used to train the punkt sentence splitter (optional, default = 5000)
+  -punktmodel string   punkt model file (JSON): read if it exists, or else trained on the dump and saved (optional)
+  -corpus string       gzip compressed output file for the cleaned plain text of the pages counted (optional)
+  -corpusfmt string    corpus format: jsonl (page id, revision id, title and text per line) or text (one sentence per line) (optional, default = jsonl)
+  -h(elp)              help: print help message
 
 Example usage:
-  $ go run wstats.go -pl 10000 https://dumps.wikimedia.org/svwiki/latest/svwiki-latest-pages-articles-multistream.xml.bz2 
+  $ go run . -pl 10000 https://dumps.wikimedia.org/svwiki/latest/svwiki-latest-pages-articles-multistream.xml.bz2 
+  $ go run . -index svwiki-latest-pages-articles-multistream-index.txt.bz2 -titles titles.txt svwiki-latest-pages-articles-multistream.xml.bz2
 
 `
 
-	var f = flag.NewFlagSet("wstats", flag.ExitOnError)
+	var f = flag.NewFlagSet("count", flag.ExitOnError)
 	var pageLimit = f.Int("pl", -1, "page limit")
 	var minFreq = f.Int("mf", 0, "min freq")
+	var format = f.String("format", formatTSV, "output format")
+	var outFile = f.String("out", "", "output file")
+	var index = f.String("index", "", "multistream index file")
+	var titles = f.String("titles", "", "file with page titles")
+	var ids = f.String("ids", "", "page ids")
+	var idRange = f.String("idrange", "", "page id range")
+	var workers = f.Int("workers", 1, "number of workers")
+	var nsAliases = f.String("nsaliases", "", "namespace aliases")
+	var magicWords = f.String("magicwords", "", "magic words")
+	var namespaces = f.String("ns", "0", "namespaces to count")
+	var parser = f.String("parser", parserWikitext, "wikitext parser")
+	var wordTokenizer = f.String("tokenizer", tokenizerRegexp, "word tokenizer")
+	var lang = f.String("lang", "", "language profile")
+	var checkpointFile = f.String("checkpoint", "", "checkpoint file")
+	var checkpointEvery = f.Int("cpevery", 100000, "pages between checkpoints")
+	var resume = f.Bool("resume", false, "resume from checkpoint")
+	var retries = f.Int("retries", download.retries, "download retries")
+	var backoff = f.Duration("backoff", download.backoff, "download retry wait")
+	var timeout = f.Duration("timeout", download.timeout, "download timeout")
+	var cacheDir = f.String("cache", "", "download cache directory")
+	var checksums = f.String("checksums", "", "checksums file")
+	var ngramN = f.Int("ngram", 0, "n-gram size")
+	var ngramMinFreq = f.Int("ngrammf", 0, "n-gram min freq")
+	var ngramFile = f.String("ngramfile", "", "n-gram output file")
+	var dispersionParts = f.Int("dispersion", 0, "no. of corpus parts for dispersion")
+	var maxMem = f.Int("maxmem", 0, "memory budget in MB")
+	var tmpDir = f.String("tmpdir", os.TempDir(), "directory for temporary files")
+	var counter = f.String("counter", counterExact, "word counter")
+	var capacity = f.Int("capacity", 100000, "capacity of the approximate counter")
+	var manifestFile = f.String("manifest", "", "manifest file")
+	var revisions = f.String("revisions", revisionsAll, "revisions to count")
+	var timeBuckets = f.String("timebuckets", "", "time buckets")
+	var timeFile = f.String("timefile", "", "time series output file")
+	var keepCase = f.Bool("case", false, "count surface forms")
+	var caseFile = f.String("casefile", "", "case variants output file")
+	var sentences = f.String("sentences", sentencesRules, "sentence splitter")
+	var punktPages = f.Int("punktpages", 5000, "no. of punkt training pages")
+	var punktModel = f.String("punktmodel", "", "punkt model file")
+	var corpusFile = f.String("corpus", "", "corpus output file")
+	var corpusFormat = f.String("corpusfmt", corpusJSONL, "corpus format")
 
-	var args = os.Args
-	if strings.HasSuffix(args[0], "wstats") {
-		args = args[1:] // remove first argument if it's the program name
-	}
 	f.Usage = func() {
 		fmt.Fprintf(os.Stderr, usage)
 	}
@@ -363,10 +1110,168 @@ Example usage:
 		os.Exit(2)
 	}
 	var file = f.Args()[0]
-	return *pageLimit, *minFreq, file
+
+	download.retries = *retries
+	download.backoff = *backoff
+	download.timeout = *timeout
+	download.cacheDir = *cacheDir
+
+	var opts = loadOptions{pageLimit: *pageLimit, logAt: 100, index: *index, workers: *workers, ngramN: *ngramN}
+	var out = outputOptions{minFreq: *minFreq, ngramMinFreq: *ngramMinFreq, ngramFile: *ngramFile, format: *format, file: *outFile, manifest: *manifestFile, timeFile: *timeFile, caseFile: *caseFile, corpusFile: *corpusFile, corpusFormat: *corpusFormat, flags: flagValues(f)}
+	if out.manifest == "" && out.file != "" {
+		out.manifest = out.file + ".manifest.json"
+	}
+	if !contains(outputFormats, *format) {
+		log.Fatal("Invalid output format: ", *format)
+	}
+	if *format == formatSQLite && *outFile == "" {
+		log.Fatal("-format sqlite requires an output file (-out)")
+	}
+	if *ngramN == 1 || *ngramN < 0 {
+		log.Fatal("Invalid n-gram size: ", *ngramN)
+	}
+	if *dispersionParts == 1 || *dispersionParts < 0 {
+		log.Fatal("Invalid no. of corpus parts for dispersion: ", *dispersionParts)
+	}
+	opts.dispersionParts = *dispersionParts
+	// document frequencies are exported to parquet, if they fit in memory
+	opts.docFreqs = *dispersionParts > 0 || (*format == formatParquet && *maxMem == 0 && *counter == counterExact)
+	if *maxMem > 0 && *dispersionParts > 0 {
+		log.Fatal("-maxmem can't be used with -dispersion")
+	}
+	opts.memBudget = *maxMem * 1024 * 1024
+	if *counter != counterExact && *counter != counterSpaceSaving {
+		log.Fatal("Invalid counter: ", *counter)
+	}
+	if *counter == counterSpaceSaving {
+		if *capacity < 1 {
+			log.Fatal("Invalid capacity: ", *capacity)
+		}
+		if *maxMem > 0 || *dispersionParts > 0 || *checkpointFile != "" {
+			log.Fatal("-counter spacesaving can't be used with -maxmem, -dispersion or -checkpoint")
+		}
+	}
+	opts.counter = *counter
+	if *revisions != revisionsAll && *revisions != revisionsLatest {
+		log.Fatal("Invalid revisions: ", *revisions)
+	}
+	opts.revisions = *revisions
+	if *timeBuckets != "" {
+		if *timeBuckets != bucketYear && *timeBuckets != bucketMonth {
+			log.Fatal("Invalid time buckets: ", *timeBuckets)
+		}
+		if *timeFile == "" && *format != formatSQLite {
+			log.Fatal("-timebuckets requires an output file for the time series (-timefile)")
+		}
+		if *format == formatParquet || *maxMem > 0 || *counter == counterSpaceSaving {
+			log.Fatal("-timebuckets can't be used with -format parquet, -maxmem or -counter spacesaving")
+		}
+	}
+	opts.timeBuckets = *timeBuckets
+	if *caseFile != "" && !*keepCase {
+		log.Fatal("-casefile requires -case")
+	}
+	if *caseFile != "" && *parser == parserLines {
+		log.Fatal("-casefile can't be used with -parser lines")
+	}
+	opts.tkOpts.keepCase = *keepCase
+	if *sentences != sentencesRules && *sentences != sentencesPunkt {
+		log.Fatal("Invalid sentence splitter: ", *sentences)
+	}
+	if *sentences == sentencesPunkt && *parser == parserLines {
+		log.Fatal("-sentences punkt can't be used with -parser lines")
+	}
+	if *punktPages < 1 {
+		log.Fatal("Invalid no. of punkt training pages: ", *punktPages)
+	}
+	opts.tkOpts.sentences = *sentences
+	opts.punktPages = *punktPages
+	opts.punktModel = *punktModel
+	// with the line based parser, each line is a sentence, so the sentence internal occurrences are not counted
+	opts.caseVariants = *keepCase && *parser != parserLines && (*caseFile != "" || *format == formatSQLite)
+	if opts.caseVariants && (*format == formatParquet || *maxMem > 0 || *counter == counterSpaceSaving) {
+		log.Fatal("-casefile can't be used with -format parquet, -maxmem or -counter spacesaving")
+	}
+	if *corpusFormat != corpusJSONL && *corpusFormat != corpusText {
+		log.Fatal("Invalid corpus format: ", *corpusFormat)
+	}
+	if *corpusFile != "" && *checkpointFile != "" {
+		log.Fatal("-corpus can't be used with -checkpoint")
+	}
+	opts.capacity = *capacity
+	opts.spillDir = *tmpDir
+	if *ngramN > 1 && *ngramFile == "" && *format != formatSQLite {
+		log.Fatal("-ngram requires an output file (-ngramfile)")
+	}
+	if *titles != "" {
+		opts.selection.titles, err = readTitles(*titles)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *ids != "" {
+		opts.selection.ids, err = parseIDs(*ids)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *idRange != "" {
+		opts.selection.idFrom, opts.selection.idTo, err = parseIDRange(*idRange)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *checkpointFile != "" {
+		opts.checkpoint = newCheckpointer(*checkpointFile, file, *checkpointEvery)
+	}
+	if *resume && *checkpointFile == "" {
+		log.Fatal("-resume requires a checkpoint file (-checkpoint)")
+	}
+	opts.resume = *resume
+	if *parser != parserWikitext && *parser != parserLines {
+		log.Fatal("Invalid parser: ", *parser)
+	}
+	opts.tkOpts.parser = *parser
+	if *wordTokenizer != tokenizerRegexp && *wordTokenizer != tokenizerUAX29 {
+		log.Fatal("Invalid tokenizer: ", *wordTokenizer)
+	}
+	opts.tkOpts.tokenizer = *wordTokenizer
+	if _, ok := langProfiles[*lang]; !ok && *lang != "" {
+		log.Fatal("Invalid language profile: ", *lang)
+	}
+	opts.tkOpts.lang = *lang
+	opts.namespaces, err = parseNamespaces(*namespaces)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *checksums != "" {
+		if *resume || (opts.index != "" && !opts.selection.isEmpty()) {
+			log.Fatal("-checksums requires the whole input file to be read (can't be used with -resume, or -index)")
+		}
+		opts.checksum, err = readChecksum(*checksums, file)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else if out.manifest != "" && !*resume && opts.pageLimit < 0 && (opts.index == "" || opts.selection.isEmpty()) {
+		// the digest of the whole input file is computed for the manifest, without verifying it
+		opts.checksum = &checksum{algorithm: "sha1", hash: sha1.New()}
+	}
+	if *nsAliases != "" {
+		opts.nsAliases, err = readNamespaceAliases(*nsAliases)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *magicWords != "" {
+		opts.imageOpts, err = readMagicWords(*magicWords)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	return opts, out, file
 }
 
-func main() {
+func countCmd(args []string) {
 
 	// Download data here: https://dumps.wikimedia.org/backup-index.html
 	// Valid input:
@@ -374,35 +1279,136 @@ func main() {
 	//   bz2 file : XXwiki-YYYYMMDD-pages-articles-multistream.xml.bz2
 	//   xml url  : implemented by not likely to be used...
 	//   bz2 url  : https://dumps.wikimedia.org/svwiki/latest/svwiki-latest-pages-articles-multistream.xml.bz2
+	//   index    : XXwiki-YYYYMMDD-pages-articles-multistream-index.txt.bz2 (for random access, with -titles, -ids or -idrange)
 
-	pageLimit, minFreq, path := loadCmdLineArgs()
+	opts, out, path := loadCmdLineArgs(args)
 
-	log.Print("*** RUNNING wstats.main() ***")
+	log.Print("*** RUNNING wstats count ***")
 	log.Print("Path : ", path)
-	if pageLimit > 0 {
-		log.Print("Page limit : ", pageLimit)
+	if opts.pageLimit > 0 {
+		log.Print("Page limit : ", opts.pageLimit)
 	} else {
 		log.Print("Page limit : ", "None")
 	}
-	log.Print("Min freq   : ", minFreq)
-
-	output := bufio.NewWriter(os.Stdout)
+	log.Print("Min freq   : ", out.minFreq)
+	if out.format != formatTSV || out.file != "" {
+		log.Print("Output     : ", out.format, " ", out.file)
+	}
+	if opts.index != "" {
+		log.Print("Index      : ", opts.index)
+	}
+	if opts.workers > 1 {
+		log.Print("Workers    : ", opts.workers)
+	}
+	log.Print("Parser     : ", opts.tkOpts.parser)
+	if opts.tkOpts.tokenizer != tokenizerRegexp {
+		log.Print("Tokenizer  : ", opts.tkOpts.tokenizer)
+	}
+	if opts.checkpoint != nil {
+		log.Print("Checkpoint : ", opts.checkpoint.path, fmt.Sprintf(" (every %d pages)", opts.checkpoint.every))
+	}
+	if download.cacheDir != "" {
+		log.Print("Cache      : ", download.cacheDir)
+	}
+	if opts.counter == counterSpaceSaving {
+		log.Print("Counter    : ", opts.counter, fmt.Sprintf(" (capacity %d)", opts.capacity))
+	}
+	if opts.memBudget > 0 {
+		log.Print("Max memory : ", opts.memBudget/(1024*1024), " MB (temp dir ", opts.spillDir, ")")
+	}
+	if opts.dispersionParts > 0 {
+		log.Print("Dispersion : ", opts.dispersionParts, " corpus parts")
+	}
+	if out.manifest != "" {
+		log.Print("Manifest   : ", out.manifest)
+	}
+	if opts.revisions != revisionsAll || opts.timeBuckets != "" {
+		log.Print("Revisions  : ", opts.revisions, " (time buckets: ", opts.timeBuckets, ") ", out.timeFile)
+	}
+	if opts.tkOpts.sentences == sentencesPunkt {
+		log.Print("Sentences  : ", opts.tkOpts.sentences, fmt.Sprintf(" (training pages %d) ", opts.punktPages), opts.punktModel)
+	}
+	if opts.tkOpts.keepCase {
+		log.Print("Case       : kept (case variants: ", opts.caseVariants, ") ", out.caseFile)
+	}
+	if opts.ngramN > 1 {
+		log.Print("N-grams    : ", opts.ngramN, " (min freq ", out.ngramMinFreq, ") ", out.ngramFile)
+	}
+	if out.corpusFile != "" {
+		log.Print("Corpus     : ", out.corpusFile, " (", out.corpusFormat, ")")
+	}
+	if opts.checksum != nil && opts.checksum.expect != "" {
+		log.Print("Checksum   : ", opts.checksum.algorithm, " ", opts.checksum.expect)
+	}
+	if opts.namespaces != nil {
+		log.Print("Namespaces : ", sortedKeys(opts.namespaces))
+	} else {
+		log.Print("Namespaces : ", "All")
+	}
 
 	start := time.Now()
-	defer output.Flush()
 
-	logAt := 100
-	result := loadXML(path, pageLimit, logAt)
+	if opts.tkOpts.sentences == sentencesPunkt {
+		m, err := loadPunktModel(opts.punktModel, path, opts, opts.punktPages)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.tkOpts.punkt = m
+		log.Print(fmt.Sprintf("Punkt model: %d abbreviations, trained on %d pages (%d tokens)", len(m.Abbreviations), m.Pages, m.Tokens))
+	}
+
+	if out.corpusFile != "" {
+		cw, err := newCorpusWriter(out.corpusFile, out.corpusFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.corpus = cw
+	}
+
+	result := loadXML(path, opts)
+
+	if opts.corpus != nil {
+		if err := opts.corpus.close(); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	loaded := time.Now()
 
-	for _, pair := range sortByWordCount(result.wordFreqs) {
-		if pair.Value >= minFreq {
-			fmt.Fprintf(output, "%d\t%s\n", pair.Value, pair.Key)
+	nUniqueWords, err := writeWordList(result, opts, out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var nUniqueNgrams int
+	if opts.ngramN > 1 {
+		nUniqueNgrams, err = writeNgramList(result, opts, out)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var nVariants int
+	if opts.caseVariants {
+		nVariants, err = writeCaseVariants(result, out)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var nBuckets int
+	if opts.timeBuckets != "" {
+		nBuckets, err = writeTimeSeries(result, opts, out)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if out.format == formatSQLite {
+		if err := writeSentenceLengths(out.file, result.sentLengths); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeSQLiteMetadata(out.file, runInfo(path, opts, result, nUniqueWords, nUniqueNgrams)); err != nil {
+			log.Fatal(err)
 		}
 	}
 
-	output.Flush() // not needed in comb. with defer.output.Flush() ?
 	end := time.Now()
 
 	clearProgress()
@@ -415,11 +1421,61 @@ func main() {
 	log.Print("Print took           : ", fmt.Sprintf("%12v\n", printDur))
 	log.Print("Total dur            : ", fmt.Sprintf("%12v\n", totalDur))
 
+	if out.manifest != "" {
+		m := newManifest(path, opts, out, result, nUniqueWords, nUniqueNgrams, loaded.Sub(start), end.Sub(loaded))
+		if err := m.write(out.manifest); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if result.siteInfo.DBName != "" {
+		log.Print("Wiki                 : ", fmt.Sprintf("%12s", result.siteInfo.DBName))
+	}
+	if result.tk != nil {
+		log.Print("Language profile     : ", fmt.Sprintf("%12s", result.tk.profile.name))
+	}
 	log.Print("No. of pages         : ", lIntPrettyPrint(result.nPages))
+	for _, ns := range sortedKeys(result.nsPages) {
+		var status = "counted"
+		if opts.namespaces != nil && !opts.namespaces[ns] {
+			status = "skipped"
+		}
+		log.Print(fmt.Sprintf("- in namespace %-6d: ", ns), lIntPrettyPrint(result.nsPages[ns]), fmt.Sprintf("  %s (%s)", result.siteInfo.namespaceLabel(ns), status))
+	}
 	log.Print("No. of redirects     : ", lIntPrettyPrint(result.nRedirects))
+	if result.nRevisions > result.nPages-result.nRedirects {
+		log.Print("No. of revisions     : ", lIntPrettyPrint(result.nRevisions))
+	}
+	if opts.timeBuckets != "" {
+		log.Print("No. of time buckets  : ", lIntPrettyPrint(nBuckets))
+	}
+	if opts.caseVariants {
+		log.Print("No. of case variants : ", lIntPrettyPrint(nVariants))
+	}
+	if opts.corpus != nil {
+		log.Print("No. of corpus texts  : ", lIntPrettyPrint(opts.corpus.nDocs))
+	}
 	log.Print("No. of lines         : ", lIntPrettyPrint(result.nLines))
 	log.Print("No. of skipped lines : ", lIntPrettyPrint(result.nLinesSkipped))
 	log.Print("No. of words         : ", lIntPrettyPrint(result.nWords))
-	log.Print("No. of unique words  : ", lIntPrettyPrint(len(result.wordFreqs)))
+	nSentences, meanLength, medianLength, p90Length, maxLength := sentenceStats(result.sentLengths)
+	log.Print("No. of sentences     : ", lIntPrettyPrint(nSentences))
+	if nSentences > 0 {
+		log.Print("Sentence length      : ", fmt.Sprintf("%12.2f", meanLength), fmt.Sprintf("  (median %d, 90%% %d, max %d words)", medianLength, p90Length, maxLength))
+	}
+	if result.approxWords != nil {
+		log.Print("No. of words kept    : ", lIntPrettyPrint(nUniqueWords))
+	} else {
+		log.Print("No. of unique words  : ", lIntPrettyPrint(nUniqueWords))
+	}
+	if opts.ngramN > 1 {
+		log.Print(fmt.Sprintf("No. of unique %d-grams: ", opts.ngramN), lIntPrettyPrint(nUniqueNgrams))
+	}
+	if result.approxWords != nil {
+		log.Print("Max count error      : ", lIntPrettyPrint(result.approxWords.errorBound()))
+		if result.approxNgrams != nil {
+			log.Print("Max n-gram error     : ", lIntPrettyPrint(result.approxNgrams.errorBound()))
+		}
+	}
 
 }
@@ -10,12 +10,13 @@ import (
 var fsExp = "Xpctd: '%v' got: '%v'"
 
 func testConvert(input string) string {
-	input = preFilterLine(input)
+	tk := defaultTokenizer
+	input = tk.preFilterLine(input)
 	var result string
-	if skip(input) {
+	if tk.skip(input) {
 		result = ""
 	} else {
-		result = convert(input)
+		result = tk.convert(input)
 	}
 	return result
 }